@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+)
+
+// getScorecardDefinitionID returns the definition in the path. The routes outside of a definition use the first
+// definition of the program, which every program gets when it's created.
+func (h *Handler) getScorecardDefinitionID(c *fiber.Ctx) (int, error) {
+	if definitionID, _ := c.ParamsInt("definitionId"); definitionID > 0 {
+		return definitionID, nil
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	var definitionID int
+	err := h.db.QueryRowContext(c.UserContext(), `
+		SELECT id
+		FROM scorecard_definitions
+		WHERE program_id = ?
+		ORDER BY id
+		LIMIT 1
+	`, programID).Scan(&definitionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, constant.ErrNotFound
+		}
+		log.Error().Err(err).Msg("definition.getScorecardDefinitionID")
+		return 0, constant.ErrInternalServerError
+	}
+
+	return definitionID, nil
+}
+
+func (h *Handler) scorecardDefinitions(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.ScorecardDefinition `json:"nodes"`
+		Error any                          `json:"error"`
+	}
+	result.Nodes = []*model.ScorecardDefinition{}
+
+	err := h.db.SelectContext(c.UserContext(), &result.Nodes, `
+		SELECT id, title, grade_scale_id
+		FROM scorecard_definitions
+		WHERE program_id = ?
+		ORDER BY id ASC
+	`, c.Params("programId"))
+	if err != nil {
+		log.Error().Err(err).Msg("definition.scorecardDefinitions")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(len(result.Nodes)))
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) saveScorecardDefinition(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	var body struct {
+		Title        string `json:"title"`
+		GradeScaleID *int   `json:"gradeScaleId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("definition.saveScorecardDefinition")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	body.Title = strings.TrimSpace(body.Title)
+	if body.Title == "" {
+		result.Error = fiber.Map{"code": "TITLE_IS_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	programID := c.Params("programId")
+
+	if body.GradeScaleID != nil {
+		var isExists bool
+		err := h.db.QueryRowContext(c.UserContext(), `
+			SELECT EXISTS (SELECT id FROM grade_scales WHERE id = ? AND program_id = ?)
+		`, body.GradeScaleID, programID).Scan(&isExists)
+		if err != nil {
+			log.Error().Err(err).Msg("definition.saveScorecardDefinition")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if !isExists {
+			result.Error = fiber.Map{"code": "INVALID_GRADE_SCALE_ID"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+	}
+
+	var err error
+	if definitionID, _ := c.ParamsInt("definitionId"); definitionID != 0 {
+		_, err = h.db.ExecContext(c.UserContext(), `
+			UPDATE scorecard_definitions
+			SET title = ?, grade_scale_id = ?
+			WHERE id = ?
+		`, body.Title, body.GradeScaleID, definitionID)
+	} else {
+		_, err = h.db.ExecContext(c.UserContext(), `
+			INSERT INTO scorecard_definitions (program_id, title, grade_scale_id)
+			VALUES (?, ?, ?)
+		`, programID, body.Title, body.GradeScaleID)
+	}
+	if err != nil {
+		if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+		log.Error().Err(err).Msg("definition.saveScorecardDefinition")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// deleteScorecardDefinition deletes the definition along with its structures and scorecards. The last definition of a
+// program can't be deleted, the routes outside of a definition depend on it.
+func (h *Handler) deleteScorecardDefinition(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	definitionID := c.Params("definitionId")
+
+	res, err := h.db.ExecContext(c.UserContext(), `
+		DELETE FROM scorecard_definitions
+		WHERE id = ?
+		  AND EXISTS (
+		    SELECT d.id
+		    FROM scorecard_definitions d
+		    WHERE d.program_id = scorecard_definitions.program_id
+		      AND d.id != scorecard_definitions.id
+		  )
+	`, definitionID)
+	if err != nil {
+		log.Error().Err(err).Msg("definition.deleteScorecardDefinition")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		var isExists bool
+		err := h.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (SELECT id FROM scorecard_definitions WHERE id = ?)`, definitionID).Scan(&isExists)
+		if err != nil {
+			log.Error().Err(err).Msg("definition.deleteScorecardDefinition")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if isExists {
+			result.Error = fiber.Map{"code": "LAST_DEFINITION_CANNOT_BE_DELETED"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
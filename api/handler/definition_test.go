@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// expectScorecardDefinitionID expects the lookup of the first definition of program 1 made by the routes outside of a
+// definition
+func expectScorecardDefinitionID(mock sqlmock.Sqlmock, definitionID int) {
+	mock.ExpectQuery("SELECT id FROM scorecard_definitions").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(definitionID))
+}
+
+func Test_getScorecardDefinitionID(t *testing.T) {
+	assert := assert.New(t)
+
+	newApp := func(h *Handler) *fiber.App {
+		app := fiber.New()
+		handler := func(c *fiber.Ctx) error {
+			definitionID, err := h.getScorecardDefinitionID(c)
+			if err == constant.ErrNotFound {
+				return c.SendStatus(fiber.StatusNotFound)
+			} else if err != nil {
+				return c.SendStatus(fiber.StatusInternalServerError)
+			}
+			return c.SendString(strconv.Itoa(definitionID))
+		}
+		app.Get("/:programId<int>", handler)
+		app.Get("/:programId<int>/:definitionId<int>", handler)
+		return app
+	}
+
+	t.Run("path", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		resp, _ := newApp(h).Test(httptest.NewRequest("GET", "/1/2", nil))
+		assert.Nil(mock.ExpectationsWereMet())
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal("2", string(body))
+	})
+
+	t.Run("first", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+
+		resp, _ := newApp(h).Test(httptest.NewRequest("GET", "/1", nil))
+		assert.Nil(mock.ExpectationsWereMet())
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal("3", string(body))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT id FROM scorecard_definitions").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		resp, _ := newApp(h).Test(httptest.NewRequest("GET", "/1", nil))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func Test_scorecardDefinitions(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM scorecard_definitions").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "grade_scale_id"}).AddRow(1, "Scorecard", 1).AddRow(2, "Midterm", nil))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/definitions", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("X-Total-Count"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"nodes":[{"id":1,"title":"Scorecard","gradeScaleId":1},{"id":2,"title":"Midterm","gradeScaleId":null}],"error":null}`, string(body))
+}
+
+func Test_saveScorecardDefinition(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("empty title", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/definitions", strings.NewReader(`{"title":" "}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"TITLE_IS_REQUIRED"}}`, string(body))
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("INSERT INTO scorecard_definitions").
+			WithArgs("1", "Midterm", nil).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/definitions", strings.NewReader(`{"title":"Midterm"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+
+	t.Run("not unique", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("UPDATE scorecard_definitions").
+			WithArgs("Scorecard", nil, 2).
+			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/definitions/2", strings.NewReader(`{"title":"Scorecard"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"TITLE_SHOULD_BE_UNIQUE"}}`, string(body))
+	})
+
+	t.Run("invalid grade scale", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT EXISTS .+ FROM grade_scales").
+			WithArgs(3, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/definitions/2", strings.NewReader(`{"title":"Final","gradeScaleId":3}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_GRADE_SCALE_ID"}}`, string(body))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT EXISTS .+ FROM grade_scales").
+			WithArgs(3, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectExec("UPDATE scorecard_definitions").
+			WithArgs("Final", 3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/definitions/2", strings.NewReader(`{"title":"Final","gradeScaleId":3}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_deleteScorecardDefinition(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("last definition", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("DELETE FROM scorecard_definitions .+ AND EXISTS").
+			WithArgs("2").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs("2").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/definitions/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"LAST_DEFINITION_CANNOT_BE_DELETED"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("DELETE FROM scorecard_definitions .+ AND EXISTS").
+			WithArgs("2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/definitions/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) gradeScales(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.GradeScale `json:"nodes"`
+		Error any                 `json:"error"`
+	}
+	result.Nodes = []*model.GradeScale{}
+
+	err := h.db.SelectContext(c.UserContext(), &result.Nodes, `
+		SELECT id, title, grades
+		FROM grade_scales
+		WHERE program_id = ?
+		ORDER BY id ASC
+	`, c.Params("programId"))
+	if err != nil {
+		log.Error().Err(err).Msg("grade.gradeScales")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(len(result.Nodes)))
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) saveGradeScale(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	var body struct {
+		Title  string       `json:"title"`
+		Grades model.Grades `json:"grades"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("grade.saveGradeScale")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	body.Title = strings.TrimSpace(body.Title)
+	if body.Title == "" {
+		result.Error = fiber.Map{"code": "TITLE_IS_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	for _, grade := range body.Grades {
+		if grade != nil {
+			grade.Grade = strings.TrimSpace(grade.Grade)
+		}
+	}
+	if err := body.Grades.Validate(); err != nil {
+		result.Error = fiber.Map{"code": "INVALID_GRADES"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	var err error
+	if gradeScaleID, _ := c.ParamsInt("gradeScaleId"); gradeScaleID != 0 {
+		_, err = h.db.ExecContext(c.UserContext(), `
+			UPDATE grade_scales
+			SET title = ?, grades = ?
+			WHERE id = ?
+		`, body.Title, body.Grades, gradeScaleID)
+	} else {
+		_, err = h.db.ExecContext(c.UserContext(), `
+			INSERT INTO grade_scales (program_id, title, grades)
+			VALUES (?, ?, ?)
+		`, c.Params("programId"), body.Title, body.Grades)
+	}
+	if err != nil {
+		if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+		log.Error().Err(err).Msg("grade.saveGradeScale")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// deleteGradeScale deletes the grade scale, the definitions that use it are left without one
+func (h *Handler) deleteGradeScale(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	_, err := h.db.ExecContext(c.UserContext(), `DELETE FROM grade_scales WHERE id = ?`, c.Params("gradeScaleId"))
+	if err != nil {
+		log.Error().Err(err).Msg("grade.deleteGradeScale")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
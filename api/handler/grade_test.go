@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_gradeScales(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM grade_scales").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "grades"}).AddRow(1, "Letter", `[{"grade":"A","minScore":90}]`))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/grade-scales", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("X-Total-Count"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"nodes":[{"id":1,"title":"Letter","grades":[{"grade":"A","minScore":90}]}],"error":null}`, string(body))
+}
+
+func Test_saveGradeScale(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("empty title", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/grade-scales", strings.NewReader(`{"title":" "}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"TITLE_IS_REQUIRED"}}`, string(body))
+	})
+
+	t.Run("invalid grades", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/grade-scales", strings.NewReader(`{"title":"Letter","grades":[{"grade":"","minScore":90}]}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_GRADES"}}`, string(body))
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("INSERT INTO grade_scales").
+			WithArgs("1", "Letter", `[{"grade":"A","minScore":90},{"grade":"B","minScore":80}]`).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/grade-scales", strings.NewReader(`{"title":"Letter","grades":[{"grade":"A","minScore":90},{"grade":" B ","minScore":80}]}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+
+	t.Run("not unique", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("UPDATE grade_scales").
+			WithArgs("Letter", "[]", 2).
+			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/grade-scales/2", strings.NewReader(`{"title":"Letter"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"TITLE_SHOULD_BE_UNIQUE"}}`, string(body))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("UPDATE grade_scales").
+			WithArgs("Pass/Fail", `[{"grade":"Pass","minScore":60}]`, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/grade-scales/2", strings.NewReader(`{"title":"Pass/Fail","grades":[{"grade":"Pass","minScore":60}]}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_deleteGradeScale(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectExec("DELETE FROM grade_scales").
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/grade-scales/2", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"slices"
+	"strconv"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// ?limit int
+// ?offset int
+// ?level int
+// ?parentId int
+
+// gradebook returns the scores of a page of users for every column in one response. The columns are the assignments by
+// default, a level shows the syllabuses of that level instead, where a score is the average of the scores under it, the
+// same way a scorecard is reduced. Cells only contain the users that have at least one score under the column.
+func (h *Handler) gradebook(c *fiber.Ctx) error {
+	type Column struct {
+		model.BaseSyllabus
+		Code     *string               `json:"code"`
+		MaxScore *float64              `json:"maxScore"`
+		Parents  []*model.BaseSyllabus `json:"parents"`
+	}
+
+	var result struct {
+		Users   []*model.User           `json:"users"`
+		Columns []*Column               `json:"columns"`
+		Cells   map[int]map[int]float64 `json:"cells"` // userID -> syllabusID -> score
+		Error   any                     `json:"error"`
+	}
+	result.Users = []*model.User{}
+	result.Columns = []*Column{}
+	result.Cells = make(map[int]map[int]float64)
+
+	programID, _ := c.ParamsInt("programId")
+	parentID := c.QueryInt("parentId")
+
+	rows, err := h.db.QueryContext(c.UserContext(), `
+		SELECT id, prev_id
+		FROM syllabus_structures
+		WHERE program_id = ?
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	structures := make(map[int]*int)
+	for rows.Next() {
+		var id int
+		var prevID *int
+		if err := rows.Scan(&id, &prevID); err != nil {
+			log.Error().Err(err).Msg("gradebook.gradebook")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		structures[id] = prevID
+	}
+
+	ids, ok := sortSyllabusStructures(structures)
+	if !ok {
+		result.Error = fiber.Map{"code": "STRUCTURES_ARE_NOT_LINEAR"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+	levels := syllabusStructureLevels(structures, ids)
+
+	level := c.QueryInt("level", len(ids))
+	if level < 0 || level > len(ids) {
+		result.Error = fiber.Map{"code": "INVALID_LEVEL"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	var syllabuses []*struct {
+		model.BaseSyllabus
+		ParentID    *int     `db:"parent_id"`
+		StructureID int      `db:"structure_id"`
+		Code        *string  `db:"code"`
+		MaxScore    *float64 `db:"max_score"`
+	}
+	err = h.db.SelectContext(c.UserContext(), &syllabuses, `
+		SELECT s.id, s.parent_id, s.structure_id, s.title, s.code, s.max_score
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		ORDER BY s.position, s.rowid
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	parents := make(map[int]*int, len(syllabuses))
+	titles := make(map[int]string, len(syllabuses))
+	children := make(map[int][]int)
+	for _, syllabus := range syllabuses {
+		parents[syllabus.ID] = syllabus.ParentID
+		titles[syllabus.ID] = syllabus.Title
+		if syllabus.ParentID != nil {
+			children[*syllabus.ParentID] = append(children[*syllabus.ParentID], syllabus.ID)
+		}
+	}
+
+	if _, ok := parents[parentID]; parentID != 0 && !ok {
+		result.Error = fiber.Map{"code": "INVALID_PARENT_ID"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	for _, syllabus := range syllabuses {
+		if levels[syllabus.StructureID] != level {
+			continue
+		}
+
+		column := Column{
+			BaseSyllabus: syllabus.BaseSyllabus,
+			Code:         syllabus.Code,
+			MaxScore:     syllabus.MaxScore,
+			Parents:      []*model.BaseSyllabus{},
+		}
+		isDescendant := parentID == 0
+		for id := syllabus.ParentID; id != nil; id = parents[*id] {
+			column.Parents = append(column.Parents, &model.BaseSyllabus{ID: *id, Title: titles[*id]})
+			isDescendant = isDescendant || *id == parentID
+		}
+		if !isDescendant {
+			continue
+		}
+		slices.Reverse(column.Parents)
+		result.Columns = append(result.Columns, &column)
+	}
+
+	qb := sq.Select().From("users").Where("program_id = ?", programID)
+
+	var totalCount int
+	if err := qb.Column("COUNT(id)").RunWith(h.db).QueryRowContext(c.UserContext()).Scan(&totalCount); err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(totalCount))
+
+	if v := c.QueryInt("limit"); v > 0 {
+		qb = qb.Limit(uint64(v))
+	}
+
+	if v := c.QueryInt("offset"); v > 0 {
+		qb = qb.Offset(uint64(v))
+	}
+
+	query, args, err := qb.Columns("id", "name").OrderBy("rowid ASC").ToSql()
+	if err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if err := h.db.SelectContext(c.UserContext(), &result.Users, query, args...); err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if len(result.Users) == 0 || len(result.Columns) == 0 {
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+
+	userIds := make([]int, len(result.Users))
+	for i, user := range result.Users {
+		userIds[i] = user.ID
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT us.user_id, us.syllabus_id, us.score
+		FROM user_scores us
+		JOIN syllabuses s ON s.id = us.syllabus_id
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		  AND us.user_id IN (?)
+	`, programID, userIds)
+	if err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	rows, err = h.db.QueryContext(c.UserContext(), query, args...)
+	if err != nil {
+		log.Error().Err(err).Msg("gradebook.gradebook")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	scores := make(map[int]map[int]float64)
+	for rows.Next() {
+		var userID, syllabusID int
+		var score float64
+		if err := rows.Scan(&userID, &syllabusID, &score); err != nil {
+			log.Error().Err(err).Msg("gradebook.gradebook")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if scores[userID] == nil {
+			scores[userID] = make(map[int]float64)
+		}
+		scores[userID][syllabusID] = score
+	}
+
+	// Missing scores count as 0, but a cell is only added if there is at least one score under it
+	var reduce func(userID, syllabusID int) (float64, bool)
+	reduce = func(userID, syllabusID int) (float64, bool) {
+		if len(children[syllabusID]) == 0 {
+			score, ok := scores[userID][syllabusID]
+			return score, ok
+		}
+
+		var total float64
+		var found bool
+		for _, childID := range children[syllabusID] {
+			score, ok := reduce(userID, childID)
+			total += score
+			found = found || ok
+		}
+		return total / float64(len(children[syllabusID])), found
+	}
+
+	for _, user := range result.Users {
+		for _, column := range result.Columns {
+			if score, ok := reduce(user.ID, column.ID); ok {
+				if result.Cells[user.ID] == nil {
+					result.Cells[user.ID] = make(map[int]float64)
+				}
+				result.Cells[user.ID][column.ID] = score
+			}
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_gradebook(t *testing.T) {
+	assert := assert.New(t)
+
+	expectQueries := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT id, prev_id FROM syllabus_structures").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "prev_id"}).AddRow(10, nil).AddRow(11, -1))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title", "code", "max_score"}).
+					AddRow(1, nil, 10, "Syllabus 1", nil, nil).
+					AddRow(2, 1, 11, "Assignment 1", "A1", 100).
+					AddRow(3, 1, 11, "Assignment 2", nil, nil).
+					AddRow(4, nil, 10, "Syllabus 2", nil, nil),
+			)
+		mock.ExpectQuery("SELECT COUNT\\(id\\) FROM users").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectQuery("SELECT id, name FROM users WHERE program_id = \\? ORDER BY rowid ASC LIMIT 2").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1").AddRow(2, "User 2"))
+		mock.ExpectQuery("SELECT .+ FROM user_scores us").
+			WithArgs(1, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "syllabus_id", "score"}).AddRow(1, 2, 90))
+	}
+
+	t.Run("invalid level", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT id, prev_id FROM syllabus_structures").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "prev_id"}).AddRow(10, nil).AddRow(11, -1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/gradebook?level=2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"users":[],"columns":[],"cells":{},"error":{"code":"INVALID_LEVEL"}}`, string(body))
+	})
+
+	t.Run("assignments", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectQueries(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/gradebook?limit=2&parentId=1", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		assert.Equal("3", resp.Header.Get("X-Total-Count"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"users":[{"id":1,"name":"User 1"},{"id":2,"name":"User 2"}],"columns":[{"id":2,"title":"Assignment 1","code":"A1","maxScore":100,"parents":[{"id":1,"title":"Syllabus 1"}]},{"id":3,"title":"Assignment 2","code":null,"maxScore":null,"parents":[{"id":1,"title":"Syllabus 1"}]}],"cells":{"1":{"2":90}},"error":null}`, string(body))
+	})
+
+	t.Run("level", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectQueries(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/gradebook?limit=2&level=0", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"users":[{"id":1,"name":"User 1"},{"id":2,"name":"User 2"}],"columns":[{"id":1,"title":"Syllabus 1","code":null,"maxScore":null,"parents":[]},{"id":4,"title":"Syllabus 2","code":null,"maxScore":null,"parents":[]}],"cells":{"1":{"1":45}},"error":null}`, string(body))
+	})
+}
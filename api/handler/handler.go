@@ -20,12 +20,31 @@ func New(db *sqlx.DB, generator scorecard.GeneratorInterface) *Handler {
 func (h *Handler) Register(r *fiber.App, m middleware.MiddlewareInterface) {
 	v1 := r.Group("/v1")
 
+	templates := v1.Group("/templates")
+	{
+		templates.Get("/", h.templates)
+		templates.Put("/:templateId<int>?", m.Template, h.saveTemplate)
+
+		templateID := templates.Group("/:templateId<int>", m.Template)
+		templateID.Get("/", h.template)
+		templateID.Post("/instantiate", h.instantiateTemplate)
+		templateID.Delete("/", h.deleteTemplate)
+	}
+
 	programs := v1.Group("/programs")
 	programs.Get("/", h.programs)
 	programs.Put("/:programId<int>?", h.saveProgram)
 
 	programID := programs.Group("/:programId<int>", m.Program)
 	programID.Get("/", h.program)
+	programID.Post("/clone", h.cloneProgram)
+	programID.Post("/finalize", h.finalizeProgram)
+	programID.Post("/unfinalize", h.unfinalizeProgram)
+	programID.Get("/lock-events", h.lockEvents)
+	programID.Put("/scores", m.NotFinalized, h.saveScores)
+	programID.Post("/scores/import", m.NotFinalized, h.importScores)
+	programID.Get("/scores/events", h.scoreEvents)
+	programID.Get("/gradebook", h.gradebook)
 	programID.Delete("/", h.deleteProgram)
 
 	users := programID.Group("/users")
@@ -36,38 +55,78 @@ func (h *Handler) Register(r *fiber.App, m middleware.MiddlewareInterface) {
 		userID := users.Group("/:userId<int>", m.User)
 		userID.Get("/", h.user)
 		userID.Get("/scores", h.userScores)
-		userID.Delete("/", h.deleteUser)
+		userID.Get("/scores/:syllabusId<int>/history", m.Syllabus, h.scoreEvents)
+		userID.Delete("/", m.NotFinalized, h.deleteUser)
 	}
 
 	syllabuses := programID.Group("/syllabuses")
 	{
 		structures := syllabuses.Group("/structures")
 		structures.Get("/", h.syllabusStructures)
-		structures.Put("/:structureId<int>?", m.SyllabusStructure, h.saveSyllabusStructure)
-		structures.Delete("/:structureId<int>", m.SyllabusStructure, h.deleteSyllabusStructure)
+		structures.Put("/:structureId<int>?", m.SyllabusStructure, m.NotFinalized, h.saveSyllabusStructure)
+		structures.Post("/:structureId<int>/move", m.SyllabusStructure, m.NotFinalized, h.moveSyllabusStructure)
+		structures.Delete("/:structureId<int>", m.SyllabusStructure, m.NotFinalized, h.deleteSyllabusStructure)
 
 		syllabuses.Get("/", h.syllabuses)
-		syllabuses.Put("/:syllabusId<int>?", m.Syllabus, h.saveSyllabus)
+		syllabuses.Get("/tree", h.syllabusTree)
+		syllabuses.Put("/:syllabusId<int>?", m.Syllabus, m.NotFinalized, h.saveSyllabus)
 
 		syllabusID := syllabuses.Group("/:syllabusId<int>", m.Syllabus)
 		syllabusID.Get("/", h.syllabus)
-		syllabusID.Delete("/", h.deleteSyllabus)
+		syllabusID.Post("/move", m.NotFinalized, h.moveSyllabus)
+		syllabusID.Post("/lock", h.lockSyllabus)
+		syllabusID.Post("/unlock", h.unlockSyllabus)
+		syllabusID.Delete("/", m.NotFinalized, h.deleteSyllabus)
 
 		scores := syllabusID.Group("/scores")
 		scores.Get("/", h.syllabusScores)
-		scores.Put("/:userId<int>", m.User, h.saveScore)
+		scores.Put("/:userId<int>", m.User, m.NotFinalized, h.saveScore)
 	}
 
 	scorecards := programID.Group("/scorecards")
 	{
-		structures := scorecards.Group("/structures")
-		structures.Get("/", h.scorecardStructures)
-		structures.Post("/copy/:syllabusId<int>", m.Syllabus, h.copySyllabusesIntoStructures)
-		structures.Put("/:structureId<int>?", m.ScorecardStructure, h.saveScorecardStructure)
-		structures.Delete("/:structureId<int>", m.ScorecardStructure, h.deleteScorecardStructure)
-
-		scorecards.Get("/", h.scorecards)
-		scorecards.Post("/generate/:scorecardId<int>?", m.Scorecard, h.generateScorecards)
-		scorecards.Get("/:scorecardId", m.Scorecard, h.scorecard)
+		schedules := scorecards.Group("/schedules")
+		schedules.Get("/", h.scorecardSchedules)
+		schedules.Put("/:scheduleId<int>?", m.ScorecardSchedule, h.saveScorecardSchedule)
+		schedules.Delete("/:scheduleId<int>", m.ScorecardSchedule, h.deleteScorecardSchedule)
+
+		scorecards.Get("/report-template", h.reportTemplate)
+		scorecards.Put("/report-template", h.saveReportTemplate)
+		scorecards.Delete("/generate", h.cancelScorecardsGeneration)
+
+		gradeScales := scorecards.Group("/grade-scales")
+		gradeScales.Get("/", h.gradeScales)
+		gradeScales.Put("/:gradeScaleId<int>?", m.GradeScale, m.NotFinalized, h.saveGradeScale)
+		gradeScales.Delete("/:gradeScaleId<int>", m.GradeScale, m.NotFinalized, h.deleteGradeScale)
+
+		definitions := scorecards.Group("/definitions")
+		definitions.Get("/", h.scorecardDefinitions)
+		definitions.Put("/:definitionId<int>?", m.ScorecardDefinition, m.NotFinalized, h.saveScorecardDefinition)
+		definitions.Delete("/:definitionId<int>", m.ScorecardDefinition, m.NotFinalized, h.deleteScorecardDefinition)
+
+		// The routes outside of a definition use the first definition of the program
+		h.registerScorecards(definitions.Group("/:definitionId<int>", m.ScorecardDefinition), m)
+		h.registerScorecards(scorecards, m)
 	}
 }
+
+func (h *Handler) registerScorecards(scorecards fiber.Router, m middleware.MiddlewareInterface) {
+	structures := scorecards.Group("/structures")
+	structures.Get("/", h.scorecardStructures)
+	structures.Get("/validate", h.validateScorecardStructures)
+	structures.Get("/export", h.exportScorecardStructures)
+	structures.Post("/import", m.NotFinalized, h.importScorecardStructures)
+	structures.Post("/copy/:syllabusId<int>", m.Syllabus, m.NotFinalized, h.copySyllabusesIntoStructures)
+	structures.Put("/:structureId<int>?", m.ScorecardStructure, m.NotFinalized, h.saveScorecardStructure)
+	structures.Post("/:structureId<int>/move", m.ScorecardStructure, m.NotFinalized, h.moveScorecardStructure)
+	structures.Delete("/:structureId<int>", m.ScorecardStructure, m.NotFinalized, h.deleteScorecardStructure)
+
+	scorecards.Get("/", h.scorecards)
+	scorecards.Get("/export", h.exportScorecards)
+	scorecards.Get("/reports.zip", h.scorecardReports)
+	scorecards.Post("/generate/:scorecardId<int>?", m.Scorecard, m.NotFinalized, h.generateScorecards)
+	scorecards.Post("/publish/:scorecardId<int>?", m.Scorecard, m.NotFinalized, h.publishScorecards)
+	scorecards.Get("/:scorecardId", m.Scorecard, h.scorecard)
+	scorecards.Get("/:scorecardId/draft", m.Scorecard, h.scorecardDraft)
+	scorecards.Get("/:scorecardId/report.pdf", m.Scorecard, h.scorecardReport)
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"strings"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// getLockedSyllabuses returns the ids of the locked syllabuses of the program, including every syllabus under them
+func getLockedSyllabuses(ctx context.Context, q sqlx.QueryerContext, programID int) (map[int]bool, error) {
+	var ids []int
+	err := sqlx.SelectContext(ctx, q, &ids, `
+		WITH RECURSIVE t AS (
+		  SELECT s.id
+		  FROM syllabuses s
+		  JOIN syllabus_structures ss ON ss.id = s.structure_id
+		  WHERE ss.program_id = ?
+		    AND s.is_locked = TRUE
+		  UNION
+		  SELECT s.id
+		  FROM syllabuses s
+		  JOIN t ON s.parent_id = t.id
+		)
+		SELECT id FROM t
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		m[id] = true
+	}
+	return m, nil
+}
+
+// setLock changes the state of a syllabus, or of the program if syllabusID is 0, and records it. Nothing is recorded
+// if the state doesn't change. Unlocking requires a reason.
+func (h *Handler) setLock(c *fiber.Ctx, syllabusID int, action string) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	// The body is optional when locking or finalizing
+	if err := c.BodyParser(&body); len(c.Body()) > 0 && err != nil {
+		log.Error().Err(err).Msg("lock.setLock")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+
+	if (action == model.LockActionUnlock || action == model.LockActionUnfinalize) && body.Reason == "" {
+		result.Error = fiber.Map{"code": "REASON_IS_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	var query string
+	var args []any
+	switch action {
+	case model.LockActionLock, model.LockActionUnlock:
+		isLocked := action == model.LockActionLock
+		query, args = `UPDATE syllabuses SET is_locked = ? WHERE id = ? AND is_locked != ?`, []any{isLocked, syllabusID, isLocked}
+	default:
+		isFinalized := action == model.LockActionFinalize
+		query, args = `UPDATE programs SET is_finalized = ? WHERE id = ? AND is_finalized != ?`, []any{isFinalized, programID, isFinalized}
+	}
+
+	var nullableSyllabusID *int
+	if syllabusID != 0 {
+		nullableSyllabusID = &syllabusID
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	res, err := tx.ExecContext(c.UserContext(), query, args...)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("lock.setLock")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if n, _ := res.RowsAffected(); n > 0 {
+		_, err := tx.ExecContext(c.UserContext(), `
+			INSERT INTO lock_events (program_id, syllabus_id, action, actor, reason)
+			VALUES (?, ?, ?, ?, ?)
+		`, programID, nullableSyllabusID, action, c.Get("X-Actor"), body.Reason)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("lock.setLock")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) lockSyllabus(c *fiber.Ctx) error {
+	syllabusID, _ := c.ParamsInt("syllabusId")
+	return h.setLock(c, syllabusID, model.LockActionLock)
+}
+
+func (h *Handler) unlockSyllabus(c *fiber.Ctx) error {
+	syllabusID, _ := c.ParamsInt("syllabusId")
+	return h.setLock(c, syllabusID, model.LockActionUnlock)
+}
+
+// finalizeProgram freezes the scores, the scorecards and the scorecard structures of the program
+func (h *Handler) finalizeProgram(c *fiber.Ctx) error {
+	return h.setLock(c, 0, model.LockActionFinalize)
+}
+
+func (h *Handler) unfinalizeProgram(c *fiber.Ctx) error {
+	return h.setLock(c, 0, model.LockActionUnfinalize)
+}
+
+func (h *Handler) lockEvents(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.LockEvent `json:"nodes"`
+		Error any                `json:"error"`
+	}
+	result.Nodes = []*model.LockEvent{}
+
+	err := h.db.SelectContext(c.UserContext(), &result.Nodes, `
+		SELECT id, syllabus_id, action, actor, reason, created_at
+		FROM lock_events
+		WHERE program_id = ?
+		ORDER BY id DESC
+	`, c.Params("programId"))
+	if err != nil {
+		log.Error().Err(err).Msg("lock.lockEvents")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	m "github.com/brantem/scorecard/middleware"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// finalizedMiddleware acts as if every program is finalized
+type finalizedMiddleware struct {
+	m.MiddlewareInterface
+}
+
+func (finalizedMiddleware) NotFinalized(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusLocked).JSON(fiber.Map{"error": fiber.Map{"code": "PROGRAM_IS_FINALIZED"}})
+}
+
+func Test_notFinalized(t *testing.T) {
+	routes := [][2]string{
+		{"PUT", "/v1/programs/1/syllabuses/structures"},
+		{"PUT", "/v1/programs/1/syllabuses/structures/2"},
+		{"POST", "/v1/programs/1/syllabuses/structures/2/move"},
+		{"PUT", "/v1/programs/1/syllabuses"},
+		{"PUT", "/v1/programs/1/syllabuses/2"},
+		{"POST", "/v1/programs/1/syllabuses/2/move"},
+		{"PUT", "/v1/programs/1/scorecards/definitions"},
+		{"PUT", "/v1/programs/1/scorecards/definitions/2"},
+	}
+
+	for _, route := range routes {
+		t.Run(route[0]+" "+route[1], func(t *testing.T) {
+			db, mock := db.New()
+			h := New(db, nil)
+
+			app := fiber.New()
+			h.Register(app, finalizedMiddleware{middleware.New()})
+
+			req := httptest.NewRequest(route[0], route[1], strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, _ := app.Test(req)
+			assert.Nil(t, mock.ExpectationsWereMet())
+			assert.Equal(t, fiber.StatusLocked, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, `{"error":{"code":"PROGRAM_IS_FINALIZED"}}`, string(body))
+		})
+	}
+}
+
+func Test_setLock(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("reason is required", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/2/unlock", strings.NewReader(`{"reason":" "}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"REASON_IS_REQUIRED"}}`, string(body))
+	})
+
+	t.Run("lock", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE syllabuses SET is_locked = \\? WHERE id = \\? AND is_locked != \\?").
+			WithArgs(true, 2, true).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO lock_events").
+			WithArgs(1, 2, "lock", "Admin", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/2/lock", nil)
+		req.Header.Set("X-Actor", "Admin")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+
+	t.Run("unfinalize", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE programs SET is_finalized = \\? WHERE id = \\? AND is_finalized != \\?").
+			WithArgs(false, 1, false).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO lock_events").
+			WithArgs(1, nil, "unfinalize", "Admin", "Late submission").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/unfinalize", strings.NewReader(`{"reason":"Late submission"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "Admin")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE programs SET is_finalized").
+			WithArgs(true, 1, true).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/finalize", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_lockEvents(t *testing.T) {
+	assert := assert.New(t)
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM lock_events WHERE program_id = \\? ORDER BY id DESC").
+		WithArgs("1").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "syllabus_id", "action", "actor", "reason", "created_at"}).
+				AddRow(2, nil, "finalize", "Admin", "", "2024-01-02 00:00:00").
+				AddRow(1, 2, "lock", "Admin", "", "2024-01-01 00:00:00"),
+		)
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/lock-events", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(mock.ExpectationsWereMet())
+	assert.Equal(fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(`{"nodes":[{"id":2,"syllabusId":null,"action":"finalize","actor":"Admin","reason":"","createdAt":"2024-01-02T00:00:00Z"},{"id":1,"syllabusId":2,"action":"lock","actor":"Admin","reason":"","createdAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
+}
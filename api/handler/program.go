@@ -1,12 +1,16 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"strconv"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/brantem/scorecard/constant"
 	"github.com/brantem/scorecard/model"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
 	"github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 )
@@ -47,7 +51,7 @@ func (h *Handler) programs(c *fiber.Ctx) error {
 		qb = qb.Offset(uint64(v))
 	}
 
-	query, args, err := qb.Columns("id", "title").OrderBy("rowid ASC").ToSql()
+	query, args, err := qb.Columns("id", "title", "is_finalized").OrderBy("rowid ASC").ToSql()
 	if err != nil {
 		log.Error().Err(err).Msg("program.programs")
 		result.Error = constant.RespInternalServerError
@@ -83,7 +87,7 @@ func (h *Handler) program(c *fiber.Ctx) error {
 
 	var program model.Program
 	err := h.db.QueryRowxContext(c.UserContext(), `
-		SELECT id, title
+		SELECT id, title, is_finalized
 		FROM programs
 		WHERE id = ?
 	`, c.Params("programId")).StructScan(&program)
@@ -124,11 +128,16 @@ func (h *Handler) saveProgram(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
 	} else {
-		_, err := h.db.ExecContext(c.UserContext(), `
+		tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+		var programID int
+		err := tx.QueryRowContext(c.UserContext(), `
 			INSERT INTO programs (title)
 			VALUES (?)
-		`, body.Title)
+			RETURNING id
+		`, body.Title).Scan(&programID)
 		if err != nil {
+			tx.Rollback()
 			if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
 				result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
 				return c.Status(fiber.StatusConflict).JSON(result)
@@ -137,6 +146,20 @@ func (h *Handler) saveProgram(c *fiber.Ctx) error {
 			result.Error = constant.RespInternalServerError
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
+
+		// The routes outside of a definition use the first one, so every program starts with one
+		_, err = tx.ExecContext(c.UserContext(), `
+			INSERT INTO scorecard_definitions (program_id, title)
+			VALUES (?, ?)
+		`, programID, model.DefaultScorecardDefinitionTitle)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("program.saveProgram")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		tx.Commit()
 	}
 
 	result.Success = true
@@ -158,3 +181,372 @@ func (h *Handler) deleteProgram(c *fiber.Ctx) error {
 	result.Success = true
 	return c.Status(fiber.StatusOK).JSON(result)
 }
+
+type programCloneMapping struct {
+	SyllabusStructures   map[int]int `json:"syllabusStructures"`
+	Syllabuses           map[int]int `json:"syllabuses"`
+	ScorecardDefinitions map[int]int `json:"scorecardDefinitions"`
+	ScorecardStructures  map[int]int `json:"scorecardStructures"`
+	Users                map[int]int `json:"users"`
+}
+
+func (h *Handler) cloneProgram(c *fiber.Ctx) error {
+	var result struct {
+		Success   bool                 `json:"success"`
+		ProgramID int                  `json:"programId"`
+		Mapping   *programCloneMapping `json:"mapping"`
+		Error     any                  `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	var body struct {
+		Title         string `json:"title"`
+		IncludeUsers  bool   `json:"includeUsers"`
+		IncludeScores bool   `json:"includeScores"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("program.cloneProgram")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// Scores belong to users, so they can't be copied without them
+	if body.IncludeScores && !body.IncludeUsers {
+		result.Error = fiber.Map{"code": "USERS_ARE_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	var newProgramID int
+	err := tx.QueryRowContext(c.UserContext(), `INSERT INTO programs (title) VALUES (?) RETURNING id`, body.Title).Scan(&newProgramID)
+	if err != nil {
+		tx.Rollback()
+		if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+		log.Error().Err(err).Msg("program.cloneProgram")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	mapping, err := copyProgram(c.UserContext(), tx, programID, newProgramID, body.IncludeUsers, body.IncludeScores)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("program.cloneProgram")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	result.ProgramID = newProgramID
+	result.Mapping = mapping
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// copyProgram copies the content of the program into the new one. Scorecards aren't copied, they can be generated
+// again from the copied scores.
+func copyProgram(ctx context.Context, tx *sqlx.Tx, programID, newProgramID int, includeUsers, includeScores bool) (*programCloneMapping, error) {
+	mapping := programCloneMapping{
+		SyllabusStructures:   make(map[int]int),
+		Syllabuses:           make(map[int]int),
+		ScorecardDefinitions: make(map[int]int),
+		ScorecardStructures:  make(map[int]int),
+		Users:                make(map[int]int),
+	}
+
+	type SyllabusStructure struct {
+		ID     int
+		PrevID *int `db:"prev_id"`
+		Title  string
+	}
+	var structures []*SyllabusStructure
+	err := tx.SelectContext(ctx, &structures, `
+		SELECT id, prev_id, title
+		FROM syllabus_structures
+		WHERE program_id = ?
+		ORDER BY rowid
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	// The links are restored once every row is copied, as a row can come before the one it points to
+	var links [][2]int
+	for _, v := range structures {
+		var prevID *int
+		if v.PrevID != nil && *v.PrevID == -1 {
+			prevID = v.PrevID
+		}
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO syllabus_structures (program_id, prev_id, title)
+			VALUES (?, ?, ?)
+			RETURNING id
+		`, newProgramID, prevID, v.Title).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		mapping.SyllabusStructures[v.ID] = id
+	}
+	for _, v := range structures {
+		if v.PrevID != nil && *v.PrevID != -1 {
+			links = append(links, [2]int{mapping.SyllabusStructures[v.ID], mapping.SyllabusStructures[*v.PrevID]})
+		}
+	}
+	if err := relinkRows(ctx, tx, "syllabus_structures", "prev_id", links); err != nil {
+		return nil, err
+	}
+
+	type Syllabus struct {
+		ID          int
+		ParentID    *int `db:"parent_id"`
+		StructureID int  `db:"structure_id"`
+		Title       string
+		model.SyllabusMetadata
+	}
+	var syllabuses []*Syllabus
+	err = tx.SelectContext(ctx, &syllabuses, `
+		SELECT s.id, s.parent_id, s.structure_id, s.title, s.description, s.code, s.position, s.tags, s.max_score, s.due_at, s.visibility
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		ORDER BY s.rowid
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range syllabuses {
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO syllabuses (structure_id, title, description, code, position, tags, max_score, due_at, visibility)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, mapping.SyllabusStructures[v.StructureID], v.Title, v.Description, v.Code, v.Position, v.Tags, v.MaxScore, v.DueAt, v.Visibility).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		mapping.Syllabuses[v.ID] = id
+	}
+	links = nil
+	for _, v := range syllabuses {
+		if v.ParentID != nil {
+			links = append(links, [2]int{mapping.Syllabuses[v.ID], mapping.Syllabuses[*v.ParentID]})
+		}
+	}
+	if err := relinkRows(ctx, tx, "syllabuses", "parent_id", links); err != nil {
+		return nil, err
+	}
+
+	var gradeScales []*model.GradeScale
+	err = tx.SelectContext(ctx, &gradeScales, `
+		SELECT id, title, grades
+		FROM grade_scales
+		WHERE program_id = ?
+		ORDER BY id
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	gradeScaleIds := make(map[int]int)
+	for _, v := range gradeScales {
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO grade_scales (program_id, title, grades)
+			VALUES (?, ?, ?)
+			RETURNING id
+		`, newProgramID, v.Title, v.Grades).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		gradeScaleIds[v.ID] = id
+	}
+
+	var definitions []*model.ScorecardDefinition
+	err = tx.SelectContext(ctx, &definitions, `
+		SELECT id, title, grade_scale_id
+		FROM scorecard_definitions
+		WHERE program_id = ?
+		ORDER BY id
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range definitions {
+		var gradeScaleID *int
+		if v.GradeScaleID != nil {
+			id := gradeScaleIds[*v.GradeScaleID]
+			gradeScaleID = &id
+		}
+
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO scorecard_definitions (program_id, title, grade_scale_id)
+			VALUES (?, ?, ?)
+			RETURNING id
+		`, newProgramID, v.Title, gradeScaleID).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		mapping.ScorecardDefinitions[v.ID] = id
+	}
+	if len(definitions) == 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO scorecard_definitions (program_id, title)
+			VALUES (?, ?)
+		`, newProgramID, model.DefaultScorecardDefinitionTitle)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	type ScorecardStructure struct {
+		ID           int
+		DefinitionID int  `db:"definition_id"`
+		ParentID     *int `db:"parent_id"`
+		Title        string
+		SyllabusID   *int `db:"syllabus_id"`
+		Position     int
+		Weight       float64
+		IsLinked     bool `db:"is_linked"`
+	}
+	var scorecardStructures []*ScorecardStructure
+	err = tx.SelectContext(ctx, &scorecardStructures, `
+		SELECT id, definition_id, parent_id, title, syllabus_id, position, weight, is_linked
+		FROM scorecard_structures
+		WHERE program_id = ?
+		ORDER BY rowid
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range scorecardStructures {
+		var syllabusID *int
+		if v.SyllabusID != nil {
+			if id, ok := mapping.Syllabuses[*v.SyllabusID]; ok {
+				syllabusID = &id
+			}
+		}
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO scorecard_structures (program_id, definition_id, title, syllabus_id, position, weight, is_linked)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING id
+		`, newProgramID, mapping.ScorecardDefinitions[v.DefinitionID], v.Title, syllabusID, v.Position, v.Weight, v.IsLinked).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		mapping.ScorecardStructures[v.ID] = id
+	}
+	links = nil
+	for _, v := range scorecardStructures {
+		if v.ParentID != nil {
+			links = append(links, [2]int{mapping.ScorecardStructures[v.ID], mapping.ScorecardStructures[*v.ParentID]})
+		}
+	}
+	if err := relinkRows(ctx, tx, "scorecard_structures", "parent_id", links); err != nil {
+		return nil, err
+	}
+
+	if !includeUsers {
+		return &mapping, nil
+	}
+
+	type User struct {
+		ID   int
+		Name string
+	}
+	var users []*User
+	err = tx.SelectContext(ctx, &users, `
+		SELECT id, name
+		FROM users
+		WHERE program_id = ?
+		ORDER BY rowid
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range users {
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO users (program_id, name)
+			VALUES (?, ?)
+			RETURNING id
+		`, newProgramID, v.Name).Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		mapping.Users[v.ID] = id
+	}
+
+	if !includeScores {
+		return &mapping, nil
+	}
+
+	type Score struct {
+		UserID     int `db:"user_id"`
+		SyllabusID int `db:"syllabus_id"`
+		Score      float64
+		Feedback   string
+		Note       string
+	}
+	var scores []*Score
+	err = tx.SelectContext(ctx, &scores, `
+		SELECT us.user_id, us.syllabus_id, us.score, us.feedback, us.note
+		FROM user_scores us
+		JOIN users u ON u.id = us.user_id
+		WHERE u.program_id = ?
+		ORDER BY us.rowid
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range scores {
+		syllabusID, ok := mapping.Syllabuses[v.SyllabusID]
+		if !ok {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO user_scores (user_id, syllabus_id, score, feedback, note)
+			VALUES (?, ?, ?, ?, ?)
+		`, mapping.Users[v.UserID], syllabusID, v.Score, v.Feedback, v.Note)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &mapping, nil
+}
+
+// relinkRows sets the column of every [id, value] pair in links
+func relinkRows(ctx context.Context, tx *sqlx.Tx, table, column string, links [][2]int) error {
+	if len(links) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(links))
+	for i, v := range links {
+		values[i] = fmt.Sprintf("(%d, %d)", v[0], v[1])
+	}
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		WITH t(id, value) AS (
+		  VALUES %s
+		)
+		UPDATE %s
+		SET %s = t.value
+		FROM t
+		WHERE %s.id = t.id
+	`, strings.Join(values, ", "), table, column, table))
+	return err
+}
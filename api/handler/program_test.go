@@ -75,7 +75,7 @@ func Test_programs(t *testing.T) {
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 		assert.Equal("2", resp.Header.Get("X-Total-Count"))
 		body, _ := io.ReadAll(resp.Body)
-		assert.Equal(`{"nodes":[{"id":2,"title":"Program 2"}],"error":null}`, string(body))
+		assert.Equal(`{"nodes":[{"id":2,"title":"Program 2","isFinalized":false}],"error":null}`, string(body))
 	})
 }
 
@@ -96,7 +96,7 @@ func Test_program(t *testing.T) {
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"program":{"id":1,"title":"Program 1"},"error":null}`, string(body))
+	assert.Equal(t, `{"program":{"id":1,"title":"Program 1","isFinalized":false},"error":null}`, string(body))
 }
 
 func Test_saveProgram(t *testing.T) {
@@ -106,9 +106,11 @@ func Test_saveProgram(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
-		mock.ExpectExec("INSERT INTO programs").
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").
 			WithArgs("Program 1").
 			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+		mock.ExpectRollback()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -127,9 +129,14 @@ func Test_saveProgram(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
-		mock.ExpectExec("INSERT INTO programs").
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").
 			WithArgs("Program 1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectExec("INSERT INTO scorecard_definitions").
+			WithArgs(1, "Scorecard").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -187,6 +194,122 @@ func Test_saveProgram(t *testing.T) {
 	})
 }
 
+func Test_cloneProgram(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("scores without users", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/clone", strings.NewReader(`{"title":"Program 2","includeScores":true}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"programId":0,"mapping":null,"error":{"code":"USERS_ARE_REQUIRED"}}`, string(body))
+	})
+
+	t.Run("not unique", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").
+			WithArgs("Program 1").
+			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/clone", strings.NewReader(`{"title":"Program 1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"programId":0,"mapping":null,"error":{"code":"TITLE_SHOULD_BE_UNIQUE"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").WithArgs("Program 2").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "prev_id", "title"}).AddRow(2, 1, "Level 2").AddRow(1, nil, "Level 1").AddRow(3, -1, "Assignment"))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2, nil, "Level 2").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2, nil, "Level 1").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(12))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2, -1, "Assignment").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(13))
+		mock.ExpectExec(`WITH .+ \(11, 12\) .+ UPDATE syllabus_structures SET prev_id`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title", "description", "code", "position", "tags", "max_score", "due_at", "visibility"}).
+					AddRow(1, nil, 1, "Syllabus 1", "", nil, 0, "[]", nil, nil, "visible").
+					AddRow(2, 1, 3, "Assignment 1", "Description 1", "A1", 1, `["quiz"]`, 100, "2024-01-01 00:00:00", "hidden"),
+			)
+		mock.ExpectQuery("INSERT INTO syllabuses").
+			WithArgs(12, "Syllabus 1", "", nil, 0, "[]", nil, nil, "visible").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(21))
+		mock.ExpectQuery("INSERT INTO syllabuses").
+			WithArgs(13, "Assignment 1", "Description 1", "A1", 1, `["quiz"]`, 100.0, "2024-01-01 00:00:00", "hidden").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(22))
+		mock.ExpectExec(`WITH .+ \(22, 21\) .+ UPDATE syllabuses SET parent_id`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectQuery("SELECT .+ FROM grade_scales").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "grades"}).AddRow(1, "Letter", `[{"grade":"A","minScore":90}]`))
+		mock.ExpectQuery("INSERT INTO grade_scales").WithArgs(2, "Letter", `[{"grade":"A","minScore":90}]`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(61))
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_definitions").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "grade_scale_id"}).AddRow(1, "Scorecard", 1).AddRow(2, "Midterm", nil))
+		mock.ExpectQuery("INSERT INTO scorecard_definitions").WithArgs(2, "Scorecard", 61).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(51))
+		mock.ExpectQuery("INSERT INTO scorecard_definitions").WithArgs(2, "Midterm", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(52))
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "definition_id", "parent_id", "title", "syllabus_id", "weight", "is_linked"}).AddRow(1, 1, nil, "Total", nil, 1, false).AddRow(2, 1, 1, "Syllabus 1", 1, 2, true))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").WithArgs(2, 51, "Total", nil, 0, 1.0, false).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(31))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").WithArgs(2, 51, "Syllabus 1", 21, 0, 2.0, true).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(32))
+		mock.ExpectExec(`WITH .+ \(32, 31\) .+ UPDATE scorecard_structures SET parent_id`).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectQuery("SELECT .+ FROM users").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1"))
+		mock.ExpectQuery("INSERT INTO users").WithArgs(2, "User 1").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(41))
+
+		mock.ExpectQuery("SELECT .+ FROM user_scores").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "syllabus_id", "score", "feedback", "note"}).AddRow(1, 2, 90, "Good", ""))
+		mock.ExpectExec("INSERT INTO user_scores").WithArgs(41, 22, 90.0, "Good", "").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/clone", strings.NewReader(`{"title":"Program 2","includeUsers":true,"includeScores":true}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"programId":2,"mapping":{"syllabusStructures":{"1":12,"2":11,"3":13},"syllabuses":{"1":21,"2":22},"scorecardDefinitions":{"1":51,"2":52},"scorecardStructures":{"1":31,"2":32},"users":{"1":41}},"error":null}`, string(body))
+	})
+}
+
 func Test_deleteProgram(t *testing.T) {
 	db, mock := db.New()
 	h := New(db, nil)
@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/brantem/scorecard/report"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) getReportTemplate(ctx context.Context, programID int) (*model.ReportTemplate, error) {
+	var tmpl model.ReportTemplate
+	err := h.db.QueryRowxContext(ctx, `
+		SELECT header, footer, logo, logo_type
+		FROM report_templates
+		WHERE program_id = ?
+	`, programID).StructScan(&tmpl)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &tmpl, nil
+		}
+		log.Error().Err(err).Msg("report.getReportTemplate")
+		return nil, constant.ErrInternalServerError
+	}
+	tmpl.HasLogo = len(tmpl.Logo) > 0
+
+	return &tmpl, nil
+}
+
+// getReportCards returns the report cards of the definition, or only the one of scorecardID if it isn't 0. The scores
+// are graded with the grade scale of the definition, if it has one.
+func (h *Handler) getReportCards(ctx context.Context, programID, definitionID, scorecardID int) ([]*report.Card, error) {
+	var programTitle string
+	var grades model.Grades
+	err := h.db.QueryRowContext(ctx, `
+		SELECT p.title, gs.grades
+		FROM programs p
+		JOIN scorecard_definitions d ON d.program_id = p.id
+		LEFT JOIN grade_scales gs ON gs.id = d.grade_scale_id
+		WHERE p.id = ?
+		  AND d.id = ?
+	`, programID, definitionID).Scan(&programTitle, &grades)
+	if err != nil {
+		log.Error().Err(err).Msg("report.getReportCards")
+		return nil, constant.ErrInternalServerError
+	}
+
+	structures, scorecards, err := h.getScorecardRows(ctx, definitionID, scorecardID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]*report.Card, len(scorecards))
+	for i, scorecard := range scorecards {
+		card := report.Card{
+			ProgramTitle: programTitle,
+			UserName:     scorecard.Name,
+			Score:        scorecard.Score,
+			IsOutdated:   scorecard.IsOutdated,
+			PublishedAt:  scorecard.PublishedAt.Time,
+			IsGraded:     len(grades) > 0,
+			Grade:        grades.Grade(scorecard.Score),
+			Items:        make([]*report.Item, len(structures)),
+		}
+		for j, structure := range structures {
+			card.Items[j] = &report.Item{Title: structure.Title, Depth: structure.Depth}
+			if score, ok := scorecard.Items[structure.ID]; ok {
+				card.Items[j].Score = &score
+				card.Items[j].Grade = grades.Grade(score)
+			}
+		}
+		cards[i] = &card
+	}
+
+	return cards, nil
+}
+
+func toReportTemplate(tmpl *model.ReportTemplate) *report.Template {
+	v := report.Template{Header: tmpl.Header, Footer: tmpl.Footer, Logo: tmpl.Logo}
+	if tmpl.LogoType != nil {
+		v.LogoType = *tmpl.LogoType
+	}
+	return &v
+}
+
+func (h *Handler) reportTemplate(c *fiber.Ctx) error {
+	var result struct {
+		Template *model.ReportTemplate `json:"template"`
+		Error    any                   `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	tmpl, err := h.getReportTemplate(c.UserContext(), programID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	result.Template = tmpl
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// saveReportTemplate expects a multipart form with header, footer and an optional logo file. The current logo is kept
+// unless a new one is uploaded or removeLogo is true.
+func (h *Handler) saveReportTemplate(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	var logo []byte
+	var logoType *string
+	replaceLogo := c.FormValue("removeLogo") == "true"
+
+	if file, err := c.FormFile("logo"); err == nil {
+		typ, err := report.LogoType(file.Filename)
+		if err != nil {
+			result.Error = fiber.Map{"code": "UNSUPPORTED_LOGO_FORMAT"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			log.Error().Err(err).Msg("report.saveReportTemplate")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		defer f.Close()
+
+		if logo, err = io.ReadAll(f); err != nil {
+			log.Error().Err(err).Msg("report.saveReportTemplate")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		if err := report.CheckLogo(logo, typ); err != nil {
+			result.Error = fiber.Map{"code": "UNSUPPORTED_LOGO_FORMAT"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+		logoType = &typ
+		replaceLogo = true
+	}
+
+	_, err := h.db.ExecContext(c.UserContext(), `
+		INSERT INTO report_templates (program_id, header, footer, logo, logo_type)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (program_id) DO UPDATE
+		SET header = excluded.header,
+		    footer = excluded.footer,
+		    logo = CASE WHEN ? THEN excluded.logo ELSE logo END,
+		    logo_type = CASE WHEN ? THEN excluded.logo_type ELSE logo_type END,
+		    updated_at = CURRENT_TIMESTAMP
+	`, c.Params("programId"), c.FormValue("header"), c.FormValue("footer"), logo, logoType, replaceLogo, replaceLogo)
+	if err != nil {
+		log.Error().Err(err).Msg("report.saveReportTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) scorecardReport(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	scorecardID, _ := c.ParamsInt("scorecardId")
+
+	// The report uses the structures of the definition the scorecard belongs to
+	var definitionID int
+	err := h.db.QueryRowContext(c.UserContext(), `SELECT definition_id FROM scorecards WHERE id = ?`, scorecardID).Scan(&definitionID)
+	if err != nil {
+		log.Error().Err(err).Msg("report.scorecardReport")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	cards, err := h.getReportCards(c.UserContext(), programID, definitionID, scorecardID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	if len(cards) == 0 {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	tmpl, err := h.getReportTemplate(c.UserContext(), programID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Render(&buf, cards[0], toReportTemplate(tmpl)); err != nil {
+		log.Error().Err(err).Msg("report.scorecardReport")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	c.Attachment(fmt.Sprintf("report-%d.pdf", scorecardID))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// scorecardReports returns a ZIP file with the report cards of every user in the definition
+func (h *Handler) scorecardReports(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	cards, err := h.getReportCards(c.UserContext(), programID, definitionID, 0)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tmpl, err := h.getReportTemplate(c.UserContext(), programID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	names := make(map[string]int)
+	for _, card := range cards {
+		// Names aren't unique, the same name gets a suffix instead of overwriting the previous file
+		name := strings.NewReplacer("/", "_", "\\", "_").Replace(card.UserName)
+		names[name]++
+		if n := names[name]; n > 1 {
+			name = fmt.Sprintf("%s (%d)", name, n)
+		}
+
+		w, err := zw.Create(name + ".pdf")
+		if err == nil {
+			err = report.Render(w, card, toReportTemplate(tmpl))
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("report.scorecardReports")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Error().Err(err).Msg("report.scorecardReports")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	c.Attachment("reports.zip")
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
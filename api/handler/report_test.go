@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/report"
+	sc "github.com/brantem/scorecard/scorecard"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectReportCards(mock sqlmock.Sqlmock, scorecardID int) {
+	if scorecardID != 0 {
+		mock.ExpectQuery("SELECT definition_id FROM scorecards").
+			WithArgs(scorecardID).
+			WillReturnRows(sqlmock.NewRows([]string{"definition_id"}).AddRow(3))
+	} else {
+		expectScorecardDefinitionID(mock, 3)
+	}
+	expectReportCardRows(mock, scorecardID)
+	mock.ExpectQuery("SELECT .+ FROM report_templates").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"header", "footer", "logo", "logo_type"}).AddRow("Header", "Footer", nil, nil))
+}
+
+func expectReportCardRows(mock sqlmock.Sqlmock, scorecardID int) {
+	mock.ExpectQuery("SELECT p.title, gs.grades FROM programs p").
+		WithArgs(1, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"title", "grades"}).AddRow("Program 1", `[{"grade":"A","minScore":90},{"grade":"B","minScore":80}]`))
+	mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+		WithArgs(3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "title", "syllabus_id"}).
+				AddRow(1, nil, "Syllabus 1", nil).
+				AddRow(2, 1, "Assignment 1", 1),
+		)
+
+	// The scorecards and their items are the ones the generator writes for the scores, then published
+	parentID, syllabusID := 1, 1
+	structures := []*sc.Structure{{ID: 1, Weight: 1}, {ID: 2, ParentID: &parentID, SyllabusID: &syllabusID, Weight: 1}}
+	userScores := []map[int]float64{{1: 90}}
+	if scorecardID == 0 {
+		userScores = append(userScores, map[int]float64{1: 80})
+	}
+
+	scorecards := sqlmock.NewRows([]string{"id", "name", "score", "is_outdated", "published_at"})
+	items := sqlmock.NewRows([]string{"scorecard_id", "structure_id", "score"})
+	for i, scores := range userScores {
+		score, nodes := sc.Reduce(structures, scores)
+		scorecards.AddRow(i+1, "User 1", score, false, "2024-01-01 00:00:00")
+		for _, node := range nodes {
+			items.AddRow(i+1, node.ID, node.Score)
+		}
+	}
+	mock.ExpectQuery("SELECT .+ FROM scorecards s JOIN users u").
+		WithArgs(3, scorecardID, scorecardID).
+		WillReturnRows(scorecards)
+	mock.ExpectQuery("SELECT .+ FROM scorecard_items si").
+		WithArgs(3, scorecardID, scorecardID).
+		WillReturnRows(items)
+}
+
+func Test_getReportCards(t *testing.T) {
+	assert := assert.New(t)
+
+	db, mock := db.New()
+	h := New(db, nil)
+
+	expectReportCardRows(mock, 0)
+
+	cards, err := h.getReportCards(context.Background(), 1, 3, 0)
+	assert.Nil(mock.ExpectationsWereMet())
+	assert.Nil(err)
+	if assert.Len(cards, 2) {
+		// Every structure has a score, not only the ones right under the roots
+		score := func(v float64) *float64 { return &v }
+		assert.Equal([]*report.Item{
+			{Title: "Syllabus 1", Depth: 0, Score: score(90), Grade: "A"},
+			{Title: "Assignment 1", Depth: 1, Score: score(90), Grade: "A"},
+		}, cards[0].Items)
+		assert.Equal([]*report.Item{
+			{Title: "Syllabus 1", Depth: 0, Score: score(80), Grade: "B"},
+			{Title: "Assignment 1", Depth: 1, Score: score(80), Grade: "B"},
+		}, cards[1].Items)
+
+		// The scores are graded with the grade scale of the definition
+		assert.True(cards[0].IsGraded)
+		assert.Equal("A", cards[0].Grade)
+		assert.Equal("B", cards[1].Grade)
+	}
+}
+
+func Test_reportTemplate(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM report_templates").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"header", "footer", "logo", "logo_type"}).AddRow("Header", "Footer", []byte("logo"), "png"))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/report-template", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"template":{"header":"Header","footer":"Footer","hasLogo":true},"error":null}`, string(body))
+}
+
+func Test_saveReportTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	var logo bytes.Buffer
+	png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+	t.Run("unsupported logo format", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		w.WriteField("header", "Header")
+		part, _ := w.CreateFormFile("logo", "logo.svg")
+		part.Write([]byte("logo"))
+		w.Close()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/report-template", &b)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"UNSUPPORTED_LOGO_FORMAT"}}`, string(body))
+	})
+
+	t.Run("logo doesn't match its extension", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		w.WriteField("header", "Header")
+		part, _ := w.CreateFormFile("logo", "logo.jpg")
+		part.Write(logo.Bytes())
+		w.Close()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/report-template", &b)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"UNSUPPORTED_LOGO_FORMAT"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("INSERT INTO report_templates .+ ON CONFLICT").
+			WithArgs("1", "Header", "Footer", logo.Bytes(), "png", true, true).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		w.WriteField("header", "Header")
+		w.WriteField("footer", "Footer")
+		part, _ := w.CreateFormFile("logo", "logo.png")
+		part.Write(logo.Bytes())
+		w.Close()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/report-template", &b)
+		req.Header.Set("Content-Type", w.FormDataContentType())
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_scorecardReport(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	expectReportCards(mock, 1)
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/1/report.pdf", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/pdf", resp.Header.Get("Content-Type"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.True(t, bytes.HasPrefix(body, []byte("%PDF-")))
+}
+
+func Test_scorecardReports(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	expectReportCards(mock, 0)
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/reports.zip", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	assert.Nil(t, err)
+	if assert.Len(t, r.File, 2) {
+		assert.Equal(t, "User 1.pdf", r.File[0].Name)
+		assert.Equal(t, "User 1 (2).pdf", r.File[1].Name)
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/brantem/scorecard/scorecard"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+func (h *Handler) scorecardSchedules(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.ScorecardSchedule `json:"nodes"`
+		Error any                        `json:"error"`
+	}
+	result.Nodes = []*model.ScorecardSchedule{}
+
+	rows, err := h.db.QueryxContext(c.UserContext(), `
+		SELECT id, expression, is_enabled, last_run_at
+		FROM scorecard_schedules
+		WHERE program_id = ?
+		ORDER BY rowid ASC
+	`, c.Params("programId"))
+	if err != nil {
+		log.Error().Err(err).Msg("schedule.scorecardSchedules")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	for rows.Next() {
+		var node model.ScorecardSchedule
+		if err := rows.StructScan(&node); err != nil {
+			log.Error().Err(err).Msg("schedule.scorecardSchedules")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if schedule, err := scorecard.ParseSchedule(node.Expression); err == nil && node.IsEnabled {
+			node.NextRunAt = &model.Time{Time: schedule.Next(now)}
+		}
+		result.Nodes = append(result.Nodes, &node)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(len(result.Nodes)))
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) saveScorecardSchedule(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	var body struct {
+		Expression string `json:"expression"`
+		IsEnabled  *bool  `json:"isEnabled"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("schedule.saveScorecardSchedule")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if _, err := scorecard.ParseSchedule(body.Expression); err != nil {
+		result.Error = fiber.Map{"code": "INVALID_EXPRESSION"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	isEnabled := true
+	if body.IsEnabled != nil {
+		isEnabled = *body.IsEnabled
+	}
+
+	if scheduleID, _ := c.ParamsInt("scheduleId"); scheduleID != 0 {
+		_, err := h.db.ExecContext(c.UserContext(), `
+			UPDATE scorecard_schedules
+			SET expression = ?, is_enabled = ?
+			WHERE id = ?
+		`, body.Expression, isEnabled, scheduleID)
+		if err != nil {
+			log.Error().Err(err).Msg("schedule.saveScorecardSchedule")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	} else {
+		// last_run_at is set so that a new schedule doesn't immediately catch up on a run from before it existed
+		_, err := h.db.ExecContext(c.UserContext(), `
+			INSERT INTO scorecard_schedules (program_id, expression, is_enabled, last_run_at)
+			VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		`, c.Params("programId"), body.Expression, isEnabled)
+		if err != nil {
+			log.Error().Err(err).Msg("schedule.saveScorecardSchedule")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) deleteScorecardSchedule(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	if _, err := h.db.ExecContext(c.UserContext(), `DELETE FROM scorecard_schedules WHERE id = ?`, c.Params("scheduleId")); err != nil {
+		log.Error().Err(err).Msg("schedule.deleteScorecardSchedule")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
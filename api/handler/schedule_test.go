@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_scorecardSchedules(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM scorecard_schedules").
+		WithArgs("1").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "expression", "is_enabled", "last_run_at"}).
+				AddRow(1, "0 0 1 1 *", false, "2024-01-01 00:00:00").
+				AddRow(2, "0 0 * * *", false, nil),
+		)
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/schedules", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("X-Total-Count"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"nodes":[{"id":1,"expression":"0 0 1 1 *","isEnabled":false,"lastRunAt":"2024-01-01T00:00:00Z","nextRunAt":null},{"id":2,"expression":"0 0 * * *","isEnabled":false,"lastRunAt":null,"nextRunAt":null}],"error":null}`, string(body))
+}
+
+func Test_saveScorecardSchedule(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("invalid expression", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/schedules", strings.NewReader(`{"expression":"every night"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_EXPRESSION"}}`, string(body))
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("INSERT INTO scorecard_schedules").
+			WithArgs("1", "0 0 * * *", true).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/schedules", strings.NewReader(`{"expression":"0 0 * * *"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectExec("UPDATE scorecard_schedules").
+			WithArgs("@daily", false, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/schedules/2", strings.NewReader(`{"expression":"@daily","isEnabled":false}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_deleteScorecardSchedule(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectExec("DELETE FROM scorecard_schedules").
+		WithArgs("2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/schedules/2", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+}
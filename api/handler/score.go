@@ -0,0 +1,625 @@
+package handler
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/brantem/scorecard/spreadsheet"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// scoreRow is a single score to save. Feedback is shown to the user on the scorecard, while the note is only for the
+// instructors. Both are kept as they are when they are omitted.
+type scoreRow struct {
+	UserID     int     `json:"userId"`
+	SyllabusID int     `json:"syllabusId"`
+	Score      float64 `json:"score"`
+	Feedback   *string `json:"feedback"`
+	Note       *string `json:"note"`
+}
+
+type scoreRowError struct {
+	Index int `json:"index"`
+	Error any `json:"error"`
+}
+
+// validateScores checks that every row points to a user and an unlocked assignment of the program, that its score is
+// within the max score of the assignment and that no cell is set more than once. q is the transaction when the rows
+// point to users that are created along with them.
+func validateScores(ctx context.Context, q sqlx.QueryerContext, programID int, rows []*scoreRow) ([]*scoreRowError, error) {
+	rowErrors := []*scoreRowError{}
+	if len(rows) == 0 {
+		return rowErrors, nil
+	}
+
+	var userIds, syllabusIds []int
+	seenUsers, seenSyllabuses := make(map[int]bool), make(map[int]bool)
+	for _, row := range rows {
+		if !seenUsers[row.UserID] {
+			seenUsers[row.UserID] = true
+			userIds = append(userIds, row.UserID)
+		}
+		if !seenSyllabuses[row.SyllabusID] {
+			seenSyllabuses[row.SyllabusID] = true
+			syllabusIds = append(syllabusIds, row.SyllabusID)
+		}
+	}
+
+	query, args, err := sqlx.In(`SELECT id FROM users WHERE program_id = ? AND id IN (?)`, programID, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	if err := sqlx.SelectContext(ctx, q, &ids, query, args...); err != nil {
+		return nil, err
+	}
+
+	users := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		users[id] = true
+	}
+
+	query, args, err = sqlx.In(`
+		SELECT s.id, s.max_score, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		  AND s.id IN (?)
+	`, programID, syllabusIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var syllabuses []struct {
+		ID           int
+		MaxScore     *float64 `db:"max_score"`
+		IsAssignment bool     `db:"is_assignment"`
+	}
+	if err := sqlx.SelectContext(ctx, q, &syllabuses, query, args...); err != nil {
+		return nil, err
+	}
+
+	maxScores := make(map[int]*float64, len(syllabuses))
+	assignments := make(map[int]bool, len(syllabuses))
+	for _, syllabus := range syllabuses {
+		maxScores[syllabus.ID] = syllabus.MaxScore
+		assignments[syllabus.ID] = syllabus.IsAssignment
+	}
+
+	locked, err := getLockedSyllabuses(ctx, q, programID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[[2]int]bool, len(rows))
+	for i, row := range rows {
+		maxScore, ok := maxScores[row.SyllabusID]
+
+		var code string
+		switch {
+		case !users[row.UserID]:
+			code = "USER_NOT_FOUND"
+		case !ok:
+			code = "SYLLABUS_NOT_FOUND"
+		case !assignments[row.SyllabusID]:
+			code = "SYLLABUS_IS_NOT_AN_ASSIGNMENT"
+		case locked[row.SyllabusID]:
+			code = "SYLLABUS_IS_LOCKED"
+		case row.Score < 0 || math.IsNaN(row.Score) || math.IsInf(row.Score, 0):
+			code = "INVALID_SCORE"
+		case maxScore != nil && row.Score > *maxScore:
+			code = "SCORE_EXCEEDS_MAX_SCORE"
+		case seen[[2]int{row.UserID, row.SyllabusID}]:
+			code = "DUPLICATE_SCORE"
+		default:
+			seen[[2]int{row.UserID, row.SyllabusID}] = true
+			continue
+		}
+		rowErrors = append(rowErrors, &scoreRowError{i, fiber.Map{"code": code}})
+	}
+
+	return rowErrors, nil
+}
+
+// scoreChange describes who changed the scores and why
+type scoreChange struct {
+	Actor  string
+	Reason string
+}
+
+// newScoreChange reads the actor from the X-Actor header, there are no accounts to take it from
+func newScoreChange(c *fiber.Ctx, reason string) *scoreChange {
+	return &scoreChange{c.Get("X-Actor"), reason}
+}
+
+// scoreChunkSize is the number of rows saved per statement, which keeps the statements well below the limit of bound
+// variables of SQLite
+const scoreChunkSize = 500
+
+// recordScoreEvents adds a score event for every row that changes the current score. It must be called before the
+// scores are saved.
+func recordScoreEvents(ctx context.Context, tx *sqlx.Tx, programID int, rows []*scoreRow, change *scoreChange) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	values := make([]string, len(rows))
+	args := make([]any, 0, len(rows)*3+3)
+	for i, row := range rows {
+		values[i] = "(?, ?, ?)"
+		args = append(args, row.UserID, row.SyllabusID, row.Score)
+	}
+	args = append(args, programID, change.Actor, change.Reason)
+
+	_, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		WITH t(user_id, syllabus_id, score) AS (VALUES %s)
+		INSERT INTO score_events (program_id, user_id, syllabus_id, old_score, new_score, actor, reason)
+		SELECT ?, t.user_id, t.syllabus_id, us.score, t.score, ?, ?
+		FROM t
+		LEFT JOIN user_scores us ON us.user_id = t.user_id AND us.syllabus_id = t.syllabus_id
+		WHERE us.score IS NOT t.score
+	`, strings.Join(values, ", ")), args...)
+	return err
+}
+
+// upsertScores upserts the rows with a statement per combination of the fields that are set, as EXCLUDED can't tell an
+// omitted feedback or note from an empty one. An omitted one keeps its current value.
+func upsertScores(ctx context.Context, tx *sqlx.Tx, rows []*scoreRow) error {
+	var keys [][2]bool
+	groups := make(map[[2]bool][]*scoreRow)
+	for _, row := range rows {
+		key := [2]bool{row.Feedback != nil, row.Note != nil}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+
+	for _, key := range keys {
+		set := []string{"score = EXCLUDED.score"}
+		if key[0] {
+			set = append(set, "feedback = EXCLUDED.feedback")
+		}
+		if key[1] {
+			set = append(set, "note = EXCLUDED.note")
+		}
+
+		qb := sq.Insert("user_scores").Columns("user_id", "syllabus_id", "score", "feedback", "note").
+			Suffix("ON CONFLICT (user_id, syllabus_id) DO UPDATE SET " + strings.Join(set, ", "))
+		for _, row := range groups[key] {
+			var feedback, note string
+			if row.Feedback != nil {
+				feedback = *row.Feedback
+			}
+			if row.Note != nil {
+				note = *row.Note
+			}
+			qb = qb.Values(row.UserID, row.SyllabusID, row.Score, feedback, note)
+		}
+
+		if _, err := qb.RunWith(tx).ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveScores upserts the scores, records the changes and marks the scorecards of the affected users as outdated. The
+// rows are saved in chunks of scoreChunkSize, all of them in the same transaction.
+func saveScores(ctx context.Context, tx *sqlx.Tx, programID int, rows []*scoreRow, change *scoreChange) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var userIds []int
+	seen := make(map[int]bool)
+	for _, row := range rows {
+		if !seen[row.UserID] {
+			seen[row.UserID] = true
+			userIds = append(userIds, row.UserID)
+		}
+	}
+
+	for chunk := range slices.Chunk(rows, scoreChunkSize) {
+		if err := recordScoreEvents(ctx, tx, programID, chunk, change); err != nil {
+			return err
+		}
+		if err := upsertScores(ctx, tx, chunk); err != nil {
+			return err
+		}
+	}
+
+	for chunk := range slices.Chunk(userIds, scoreChunkSize) {
+		query, args, err := sqlx.In(`UPDATE scorecards SET is_outdated = TRUE WHERE program_id = ? AND user_id IN (?)`, programID, chunk)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) saveScores(c *fiber.Ctx) error {
+	var result struct {
+		Success bool             `json:"success"`
+		Errors  []*scoreRowError `json:"errors"`
+		Error   any              `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	var body struct {
+		Scores []*scoreRow `json:"scores"`
+		Reason string      `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("score.saveScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	rowErrors, err := validateScores(c.UserContext(), h.db, programID, body.Scores)
+	if err != nil {
+		log.Error().Err(err).Msg("score.saveScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	result.Errors = rowErrors
+
+	// Nothing is saved unless every row is valid
+	if len(rowErrors) > 0 {
+		result.Error = fiber.Map{"code": "INVALID_SCORES"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	if err := saveScores(c.UserContext(), tx, programID, body.Scores, newScoreChange(c, body.Reason)); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("score.saveScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// ?dryRun bool
+// ?createUsers bool
+
+// importScores reads a CSV or XLSX file with the users as rows and the assignments as columns. The first column holds
+// the names of the users, and the header of every other column holds the code or the title of an assignment.
+func (h *Handler) importScores(c *fiber.Ctx) error {
+	type Column struct {
+		Column     int    `json:"column"`
+		Header     string `json:"header"`
+		SyllabusID int    `json:"syllabusId"`
+	}
+
+	type InvalidValue struct {
+		Row    int    `json:"row"`
+		Column int    `json:"column"`
+		Value  string `json:"value"`
+		Error  any    `json:"error"`
+	}
+
+	type Preview struct {
+		Columns          []*Column       `json:"columns"`
+		UnmatchedColumns []string        `json:"unmatchedColumns"`
+		UnmatchedUsers   []string        `json:"unmatchedUsers"`
+		NewUsers         []string        `json:"newUsers"`
+		InvalidValues    []*InvalidValue `json:"invalidValues"`
+		ScoreCount       int             `json:"scoreCount"`
+	}
+
+	var result struct {
+		Success bool     `json:"success"`
+		Preview *Preview `json:"preview"`
+		Error   any      `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	createUsers := c.QueryBool("createUsers")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		result.Error = fiber.Map{"code": "FILE_IS_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	format, err := spreadsheet.FormatFromFilename(file.Filename)
+	if err != nil {
+		result.Error = fiber.Map{"code": "UNSUPPORTED_FORMAT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("score.importScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer f.Close()
+
+	rows, err := spreadsheet.Read(f, format)
+	if err != nil || len(rows) == 0 {
+		result.Error = fiber.Map{"code": "INVALID_FILE"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	var users []struct {
+		ID   int
+		Name string
+	}
+	err = h.db.SelectContext(c.UserContext(), &users, `SELECT id, name FROM users WHERE program_id = ?`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("score.importScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	userIds := make(map[string]int, len(users))
+	for _, user := range users {
+		userIds[user.Name] = user.ID
+	}
+
+	var assignments []struct {
+		ID    int
+		Title string
+		Code  *string
+	}
+	err = h.db.SelectContext(c.UserContext(), &assignments, `
+		SELECT s.id, s.title, s.code
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		  AND ss.prev_id = -1
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("score.importScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// Codes are matched before titles, as a title might look like the code of another assignment
+	syllabusIds := make(map[string]int, len(assignments)*2)
+	for _, assignment := range assignments {
+		syllabusIds[assignment.Title] = assignment.ID
+	}
+	for _, assignment := range assignments {
+		if assignment.Code != nil {
+			syllabusIds[*assignment.Code] = assignment.ID
+		}
+	}
+
+	preview := Preview{
+		Columns:          []*Column{},
+		UnmatchedColumns: []string{},
+		UnmatchedUsers:   []string{},
+		NewUsers:         []string{},
+		InvalidValues:    []*InvalidValue{},
+	}
+	result.Preview = &preview
+
+	columns := make(map[int]int) // index -> syllabusID
+	matched := make(map[int]bool)
+	for i, header := range rows[0] {
+		if i == 0 {
+			continue
+		}
+		header = strings.TrimSpace(header)
+		if id, ok := syllabusIds[header]; ok && !matched[id] {
+			matched[id] = true
+			columns[i] = id
+			preview.Columns = append(preview.Columns, &Column{i + 1, header, id})
+		} else if header != "" {
+			preview.UnmatchedColumns = append(preview.UnmatchedColumns, header)
+		}
+	}
+
+	type Score struct {
+		Name       string
+		SyllabusID int
+		Score      float64
+		Cell       *InvalidValue // the cell the score comes from, reported if the score is invalid
+	}
+	var scores []*Score
+
+	seen := make(map[string]bool)
+	for i, row := range rows[1:] {
+		if len(row) == 0 {
+			continue
+		}
+
+		name := strings.TrimSpace(row[0])
+		if name == "" {
+			continue
+		}
+
+		if seen[name] {
+			preview.InvalidValues = append(preview.InvalidValues, &InvalidValue{i + 2, 1, row[0], fiber.Map{"code": "DUPLICATE_USER"}})
+			continue
+		}
+		seen[name] = true
+
+		if _, ok := userIds[name]; !ok {
+			if !createUsers {
+				preview.UnmatchedUsers = append(preview.UnmatchedUsers, name)
+				continue
+			}
+			preview.NewUsers = append(preview.NewUsers, name)
+		}
+
+		for j, value := range row {
+			syllabusID, ok := columns[j]
+			if !ok || strings.TrimSpace(value) == "" {
+				continue
+			}
+
+			score, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				preview.InvalidValues = append(preview.InvalidValues, &InvalidValue{i + 2, j + 1, value, fiber.Map{"code": "INVALID_SCORE"}})
+				continue
+			}
+			scores = append(scores, &Score{name, syllabusID, score, &InvalidValue{Row: i + 2, Column: j + 1, Value: value}})
+		}
+	}
+
+	// The new users are created before the scores are validated, so their scores go through the same checks and the
+	// same upsert as the scores of the existing users. A dry run rolls them back along with everything else.
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	for _, name := range preview.NewUsers {
+		var id int
+		err := tx.QueryRowContext(c.UserContext(), `INSERT INTO users (program_id, name) VALUES (?, ?) RETURNING id`, programID, name).Scan(&id)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("score.importScores")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		userIds[name] = id
+	}
+
+	scoreRows := make([]*scoreRow, len(scores))
+	for i, score := range scores {
+		scoreRows[i] = &scoreRow{UserID: userIds[score.Name], SyllabusID: score.SyllabusID, Score: score.Score}
+	}
+
+	rowErrors, err := validateScores(c.UserContext(), tx, programID, scoreRows)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("score.importScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	for _, rowError := range rowErrors {
+		cell := scores[rowError.Index].Cell
+		cell.Error = rowError.Error
+		preview.InvalidValues = append(preview.InvalidValues, cell)
+	}
+	slices.SortStableFunc(preview.InvalidValues, func(a, b *InvalidValue) int {
+		return cmp.Or(cmp.Compare(a.Row, b.Row), cmp.Compare(a.Column, b.Column))
+	})
+	preview.ScoreCount = len(scores) - len(rowErrors)
+
+	if c.QueryBool("dryRun") {
+		tx.Rollback()
+		result.Success = true
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+
+	if len(preview.InvalidValues) > 0 {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "INVALID_VALUES"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	if err := saveScores(c.UserContext(), tx, programID, scoreRows, newScoreChange(c, c.FormValue("reason"))); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("score.importScores")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// ?userId int
+// ?syllabusId int
+// ?actor string
+// ?limit int
+// ?offset int
+
+// scoreEvents returns the score events of the program from the newest. The history of a single score is returned when
+// the userId and the syllabusId are part of the route.
+func (h *Handler) scoreEvents(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.ScoreEvent `json:"nodes"`
+		Error any                 `json:"error"`
+	}
+	result.Nodes = []*model.ScoreEvent{}
+
+	qb := sq.Select().From("score_events").Where("program_id = ?", c.Params("programId"))
+
+	userID, _ := c.ParamsInt("userId")
+	if userID == 0 {
+		userID = c.QueryInt("userId")
+	}
+	if userID != 0 {
+		qb = qb.Where("user_id = ?", userID)
+	}
+
+	syllabusID, _ := c.ParamsInt("syllabusId")
+	if syllabusID == 0 {
+		syllabusID = c.QueryInt("syllabusId")
+	}
+	if syllabusID != 0 {
+		qb = qb.Where("syllabus_id = ?", syllabusID)
+	}
+
+	if v := c.Query("actor"); v != "" {
+		qb = qb.Where("actor = ?", v)
+	}
+
+	var totalCount int
+	if err := qb.Column("COUNT(id)").RunWith(h.db).QueryRowContext(c.UserContext()).Scan(&totalCount); err != nil {
+		log.Error().Err(err).Msg("score.scoreEvents")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(totalCount))
+
+	if totalCount == 0 {
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+
+	if v := c.QueryInt("limit"); v > 0 {
+		qb = qb.Limit(uint64(v))
+	}
+
+	if v := c.QueryInt("offset"); v > 0 {
+		qb = qb.Offset(uint64(v))
+	}
+
+	query, args, err := qb.
+		Columns("id", "user_id", "syllabus_id", "old_score", "new_score", "actor", "reason", "created_at").
+		OrderBy("id DESC").
+		ToSql()
+	if err != nil {
+		log.Error().Err(err).Msg("score.scoreEvents")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if err := h.db.SelectContext(c.UserContext(), &result.Nodes, query, args...); err != nil {
+		log.Error().Err(err).Msg("score.scoreEvents")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
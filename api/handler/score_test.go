@@ -0,0 +1,341 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_saveScores(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("invalid", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT id FROM users").
+			WithArgs(1, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1, 1, 3, 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "max_score", "is_assignment"}).AddRow(1, 100, true).AddRow(4, nil, false))
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ s.is_locked = TRUE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		data := `{"scores":[
+			{"userId":1,"syllabusId":1,"score":90},
+			{"userId":2,"syllabusId":1,"score":90},
+			{"userId":1,"syllabusId":3,"score":90},
+			{"userId":1,"syllabusId":4,"score":90},
+			{"userId":1,"syllabusId":1,"score":-1},
+			{"userId":1,"syllabusId":1,"score":101},
+			{"userId":1,"syllabusId":1,"score":80}
+		]}`
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scores", strings.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"errors":[{"index":1,"error":{"code":"USER_NOT_FOUND"}},{"index":2,"error":{"code":"SYLLABUS_NOT_FOUND"}},{"index":3,"error":{"code":"SYLLABUS_IS_NOT_AN_ASSIGNMENT"}},{"index":4,"error":{"code":"INVALID_SCORE"}},{"index":5,"error":{"code":"SCORE_EXCEEDS_MAX_SCORE"}},{"index":6,"error":{"code":"DUPLICATE_SCORE"}}],"error":{"code":"INVALID_SCORES"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT id FROM users").
+			WithArgs(1, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(2))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1, 1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "max_score", "is_assignment"}).AddRow(1, nil, true).AddRow(2, 100, true))
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ s.is_locked = TRUE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO score_events").
+			WithArgs(1, 1, 90.0, 2, 1, 80.0, 1, 2, 100.0, 1, "Admin", "Midterm").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(`INSERT INTO user_scores .+ ON CONFLICT \(user_id, syllabus_id\) DO UPDATE SET score = EXCLUDED.score$`).
+			WithArgs(1, 1, 90.0, "", "", 1, 2, 100.0, "", "").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(`INSERT INTO user_scores .+ DO UPDATE SET score = EXCLUDED.score, feedback = EXCLUDED.feedback$`).
+			WithArgs(2, 1, 80.0, "Good", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(1, 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		data := `{"scores":[{"userId":1,"syllabusId":1,"score":90},{"userId":2,"syllabusId":1,"score":80,"feedback":"Good"},{"userId":1,"syllabusId":2,"score":100}],"reason":"Midterm"}`
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scores", strings.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "Admin")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"errors":[],"error":null}`, string(body))
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		db, mock := db.New()
+
+		rows := make([]*scoreRow, scoreChunkSize+1)
+		for i := range rows {
+			rows[i] = &scoreRow{UserID: i + 1, SyllabusID: 1, Score: 90}
+		}
+
+		mock.ExpectBegin()
+		for range 2 {
+			mock.ExpectExec("INSERT INTO score_events").WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectExec("INSERT INTO user_scores").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectExec("UPDATE scorecards").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(1, scoreChunkSize+1).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		tx := db.MustBegin()
+		assert.Nil(saveScores(context.Background(), tx, 1, rows, &scoreChange{}))
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+}
+
+func newImportScoresRequest(query, filename, data string) *http.Request {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write([]byte(data))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/v1/programs/1/scores/import"+query, &b)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func Test_importScores(t *testing.T) {
+	assert := assert.New(t)
+
+	data := "Name,A1,Assignment 2,Unknown\nUser 1,90,100,1\nUser 2,abc,101,\nUser 3,80,,\n"
+
+	expectLookups := func(mock sqlmock.Sqlmock) {
+		mock.ExpectQuery("SELECT id, name FROM users").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1").AddRow(2, "User 2"))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "title", "code"}).
+					AddRow(1, "Assignment 1", "A1").
+					AddRow(2, "Assignment 2", nil),
+			)
+	}
+
+	// The scores are validated the same way as the ones saved through saveScores
+	expectValidateScores := func(mock sqlmock.Sqlmock, userIds ...int) {
+		args := []driver.Value{1}
+		users := sqlmock.NewRows([]string{"id"})
+		for _, id := range userIds {
+			args = append(args, id)
+			users.AddRow(id)
+		}
+		mock.ExpectQuery("SELECT id FROM users").
+			WithArgs(args...).
+			WillReturnRows(users)
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1, 1, 2).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "max_score", "is_assignment"}).
+					AddRow(1, nil, true).
+					AddRow(2, 100, true),
+			)
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ s.is_locked = TRUE").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportScoresRequest("", "scores.txt", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"preview":null,"error":{"code":"UNSUPPORTED_FORMAT"}}`, string(body))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectLookups(mock)
+		mock.ExpectBegin()
+		expectValidateScores(mock, 1, 2)
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportScoresRequest("?dryRun=true", "scores.csv", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"preview":{"columns":[{"column":2,"header":"A1","syllabusId":1},{"column":3,"header":"Assignment 2","syllabusId":2}],"unmatchedColumns":["Unknown"],"unmatchedUsers":["User 3"],"newUsers":[],"invalidValues":[{"row":3,"column":2,"value":"abc","error":{"code":"INVALID_SCORE"}},{"row":3,"column":3,"value":"101","error":{"code":"SCORE_EXCEEDS_MAX_SCORE"}}],"scoreCount":2},"error":null}`, string(body))
+	})
+
+	t.Run("dry run with new users", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectLookups(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO users").
+			WithArgs(1, "User 3").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		expectValidateScores(mock, 1, 2, 3)
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportScoresRequest("?dryRun=true&createUsers=true", "scores.csv", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"preview":{"columns":[{"column":2,"header":"A1","syllabusId":1},{"column":3,"header":"Assignment 2","syllabusId":2}],"unmatchedColumns":["Unknown"],"unmatchedUsers":[],"newUsers":["User 3"],"invalidValues":[{"row":3,"column":2,"value":"abc","error":{"code":"INVALID_SCORE"}},{"row":3,"column":3,"value":"101","error":{"code":"SCORE_EXCEEDS_MAX_SCORE"}}],"scoreCount":3},"error":null}`, string(body))
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectLookups(mock)
+		mock.ExpectBegin()
+		expectValidateScores(mock, 1, 2)
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportScoresRequest("", "scores.csv", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectLookups(mock)
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO users").
+			WithArgs(1, "User 3").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		expectValidateScores(mock, 1, 2, 3)
+		mock.ExpectExec("INSERT INTO score_events").
+			WithArgs(1, 1, 90.0, 1, 2, 100.0, 2, 2, 95.0, 3, 1, 80.0, 1, "", "").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec("INSERT INTO user_scores .+ ON CONFLICT").
+			WithArgs(1, 1, 90.0, "", "", 1, 2, 100.0, "", "", 2, 2, 95.0, "", "", 3, 1, 80.0, "", "").
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(1, 1, 2, 3).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		data := "Name,A1,Assignment 2\nUser 1,90,100\nUser 2,,95\nUser 3,80,\n"
+		resp, _ := app.Test(newImportScoresRequest("?createUsers=true", "scores.csv", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"preview":{"columns":[{"column":2,"header":"A1","syllabusId":1},{"column":3,"header":"Assignment 2","syllabusId":2}],"unmatchedColumns":[],"unmatchedUsers":[],"newUsers":["User 3"],"invalidValues":[],"scoreCount":4},"error":null}`, string(body))
+	})
+}
+
+func Test_scoreEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("program", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery(`SELECT COUNT\(id\) FROM score_events WHERE program_id = \? AND user_id = \? AND actor = \?`).
+			WithArgs("1", 2, "Admin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(`SELECT .+ FROM score_events WHERE program_id = \? AND user_id = \? AND actor = \? ORDER BY id DESC LIMIT 10`).
+			WithArgs("1", 2, "Admin").
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "syllabus_id", "old_score", "new_score", "actor", "reason", "created_at"}).
+					AddRow(1, 2, 3, nil, 90, "Admin", "", "2024-01-01 00:00:00"),
+			)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scores/events?userId=2&actor=Admin&limit=10", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		assert.Equal("1", resp.Header.Get("X-Total-Count"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"nodes":[{"id":1,"userId":2,"syllabusId":3,"oldScore":null,"newScore":90,"actor":"Admin","reason":"","createdAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
+	})
+
+	t.Run("history", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery(`SELECT COUNT\(id\) FROM score_events WHERE program_id = \? AND user_id = \? AND syllabus_id = \?`).
+			WithArgs("1", 2, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(`SELECT .+ FROM score_events WHERE program_id = \? AND user_id = \? AND syllabus_id = \? ORDER BY id DESC`).
+			WithArgs("1", 2, 3).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "syllabus_id", "old_score", "new_score", "actor", "reason", "created_at"}).
+					AddRow(2, 2, 3, 90, 95, "Admin", "Regraded", "2024-01-02 00:00:00").
+					AddRow(1, 2, 3, nil, 90, "Admin", "", "2024-01-01 00:00:00"),
+			)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/users/2/scores/3/history", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"nodes":[{"id":2,"userId":2,"syllabusId":3,"oldScore":90,"newScore":95,"actor":"Admin","reason":"Regraded","createdAt":"2024-01-02T00:00:00Z"},{"id":1,"userId":2,"syllabusId":3,"oldScore":null,"newScore":90,"actor":"Admin","reason":"","createdAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
+	})
+}
@@ -1,16 +1,20 @@
 package handler
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/brantem/scorecard/constant"
 	"github.com/brantem/scorecard/model"
 	"github.com/brantem/scorecard/scorecard"
+	"github.com/brantem/scorecard/spreadsheet"
 	"github.com/gofiber/fiber/v2"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
@@ -22,7 +26,7 @@ func (h *Handler) getScorecardStructureSyllabuses(ctx context.Context, ids []int
 	}
 
 	query, args, err := sqlx.In(`
-		SELECT s.id, s.title, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
+		SELECT s.id, s.title, s.description, s.code, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
 		FROM syllabuses s
 		JOIN syllabus_structures ss ON ss.id = s.structure_id
 		WHERE s.id IN (?)
@@ -61,7 +65,16 @@ func (h *Handler) scorecardStructures(c *fiber.Ctx) error {
 	}
 	result.Nodes = []*model.ScorecardStructure{}
 
-	programID, _ := c.ParamsInt("programId")
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
 	depth := c.QueryInt("depth")
 
 	if c.Method() == fiber.MethodHead {
@@ -69,8 +82,8 @@ func (h *Handler) scorecardStructures(c *fiber.Ctx) error {
 		err := h.db.QueryRowxContext(c.UserContext(), `
 			SELECT COUNT(id)
 			FROM scorecard_structures
-			WHERE program_id = ?
-		`, programID).Scan(&totalCount)
+			WHERE definition_id = ?
+		`, definitionID).Scan(&totalCount)
 		if err != nil {
 			log.Error().Err(err).Msg("scorecard.scorecardStructures")
 			result.Error = constant.RespInternalServerError
@@ -85,7 +98,7 @@ func (h *Handler) scorecardStructures(c *fiber.Ctx) error {
 		WITH RECURSIVE t AS (
 		  SELECT *, rowid, 1 AS depth
 		  FROM scorecard_structures
-		  WHERE program_id = ?
+		  WHERE definition_id = ?
 		    AND parent_id IS NULL
 		  UNION ALL
 		  SELECT ss.*, ss.rowid, t.depth + 1
@@ -93,9 +106,9 @@ func (h *Handler) scorecardStructures(c *fiber.Ctx) error {
 		  JOIN t ON ss.parent_id = t.id
 		  WHERE (? = 0 OR t.depth < ?)
 		)
-		SELECT id, parent_id, title, syllabus_id FROM t
-		ORDER BY t.rowid
-	`, programID, depth, depth)
+		SELECT id, parent_id, title, syllabus_id, position, weight, is_linked FROM t
+		ORDER BY t.position, t.rowid
+	`, definitionID, depth, depth)
 	if err != nil {
 		log.Error().Err(err).Msg("scorecard.scorecardStructures")
 		result.Error = constant.RespInternalServerError
@@ -137,6 +150,7 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 
 	var body struct {
 		ParentID *int `json:"parentId"`
+		IsLinked bool `json:"isLinked"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		log.Error().Err(err).Msg("scorecard.copySyllabusesIntoStructures")
@@ -147,6 +161,26 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 	programID, _ := c.ParamsInt("programId")
 	targetID, _ := c.ParamsInt("syllabusId")
 
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if body.IsLinked {
+		if err := h.linkSyllabusesIntoStructures(c.UserContext(), programID, definitionID, targetID, body.ParentID); err != nil {
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		result.Success = true
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+
 	syllabuses := make(map[int]*int)
 	var syllabusIds []int
 
@@ -183,7 +217,7 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 		syllabusIds = append(syllabusIds, syllabusID)
 	}
 
-	query, args, err := sqlx.In(`SELECT ?, title, id FROM syllabuses WHERE id IN (?)`, programID, syllabusIds)
+	query, args, err := sqlx.In(`SELECT ?, ?, title, id, position FROM syllabuses WHERE id IN (?)`, programID, definitionID, syllabusIds)
 	if err != nil {
 		log.Error().Err(err).Msg("scorecard.copySyllabusesIntoStructures")
 		result.Error = constant.RespInternalServerError
@@ -192,7 +226,27 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 
 	tx := h.db.MustBeginTx(c.UserContext(), nil)
 
-	rows, err = tx.QueryContext(c.UserContext(), fmt.Sprintf(`INSERT INTO scorecard_structures (program_id, title, syllabus_id) %s RETURNING id, syllabus_id`, query), args...)
+	// The copied structures keep the order of their syllabuses and are added after the structures that are already under
+	// the parent
+	topParentID := body.ParentID
+	if targetID == 0 {
+		topParentID = nil
+	}
+	var shift int
+	err = tx.QueryRowContext(c.UserContext(), `
+		SELECT COALESCE(MAX(position) + 1, 0)
+		FROM scorecard_structures
+		WHERE definition_id = ?
+		  AND parent_id IS ?
+	`, definitionID, topParentID).Scan(&shift)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.copySyllabusesIntoStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	rows, err = tx.QueryContext(c.UserContext(), fmt.Sprintf(`INSERT INTO scorecard_structures (program_id, definition_id, title, syllabus_id, position) %s RETURNING id, syllabus_id`, query), args...)
 	if err != nil {
 		tx.Rollback()
 		log.Error().Err(err).Msg("scorecard.copySyllabusesIntoStructures")
@@ -214,29 +268,41 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 	}
 
 	values := make([]string, 0, len(structures))
-	for syllabusID, structureID := range structures {
+	for _, syllabusID := range syllabusIds {
+		structureID, ok := structures[syllabusID]
+		if !ok {
+			continue
+		}
+
 		var newID *int
+		isTop := true
 		if syllabusID == targetID {
 			newID = body.ParentID
 		} else if v := syllabuses[syllabusID]; v != nil {
 			if _newID, ok := structures[*v]; ok {
 				newID = &_newID
+				isTop = false
 			}
 		}
 
+		var _shift int
+		if isTop {
+			_shift = shift
+		}
+
 		if newID != nil {
-			values = append(values, fmt.Sprintf("(%d, %d)", structureID, *newID))
+			values = append(values, fmt.Sprintf("(%d, %d, %d)", structureID, *newID, _shift))
 		} else {
-			values = append(values, fmt.Sprintf("(%d, NULL)", structureID))
+			values = append(values, fmt.Sprintf("(%d, NULL, %d)", structureID, _shift))
 		}
 	}
 
 	_, err = tx.ExecContext(c.UserContext(), fmt.Sprintf(`
-		WITH t(id, parent_id) AS (
+		WITH t(id, parent_id, shift) AS (
 		  VALUES %s
 		)
 		UPDATE scorecard_structures
-		SET parent_id = t.parent_id
+		SET parent_id = t.parent_id, position = scorecard_structures.position + t.shift
 		FROM t
 		WHERE scorecard_structures.id = t.id
 	`, strings.Join(values, ", ")))
@@ -247,7 +313,7 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
 
-	_, err = tx.ExecContext(c.UserContext(), `UPDATE scorecards SET is_outdated = TRUE WHERE program_id = ?`, programID)
+	_, err = tx.ExecContext(c.UserContext(), `UPDATE scorecards SET is_outdated = TRUE WHERE definition_id = ?`, definitionID)
 	if err != nil {
 		tx.Rollback()
 		log.Error().Err(err).Msg("scorecard.copySyllabusesIntoStructures")
@@ -261,6 +327,223 @@ func (h *Handler) copySyllabusesIntoStructures(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
+// linkSyllabusesIntoStructures adds a linked structure for the syllabus, or for every root syllabus if syllabusID is 0,
+// and fills it with the subtree of the syllabus. A syllabus that is already linked in the definition isn't linked again,
+// which makes it safe to run more than once.
+func (h *Handler) linkSyllabusesIntoStructures(ctx context.Context, programID, definitionID, syllabusID int, parentID *int) error {
+	tx := h.db.MustBeginTx(ctx, nil)
+
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO scorecard_structures (program_id, definition_id, parent_id, title, syllabus_id, position, is_linked)
+		SELECT ?, ?, ?, s.title, s.id, ROW_NUMBER() OVER (ORDER BY s.position, s.rowid) - 1 + (
+		  SELECT COALESCE(MAX(position) + 1, 0)
+		  FROM scorecard_structures
+		  WHERE definition_id = ?
+		    AND parent_id IS ?
+		), TRUE
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		  AND ((? = 0 AND s.parent_id IS NULL) OR s.id = ?)
+		  AND NOT EXISTS (
+		    SELECT id
+		    FROM scorecard_structures
+		    WHERE definition_id = ?
+		      AND syllabus_id = s.id
+		      AND is_linked = TRUE
+		  )
+		ORDER BY s.position, s.rowid
+	`, programID, definitionID, parentID, definitionID, parentID, programID, syllabusID, syllabusID, definitionID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.linkSyllabusesIntoStructures")
+		return constant.ErrInternalServerError
+	}
+
+	if err := syncLinkedStructures(ctx, tx, programID); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.linkSyllabusesIntoStructures")
+		return constant.ErrInternalServerError
+	}
+
+	tx.Commit()
+
+	return nil
+}
+
+// syncLinkedStructures makes every linked structure mirror the subtree of its syllabus. The structures of new
+// syllabuses are added, titles and parents follow the syllabuses and the structures of syllabuses that left the subtree
+// are deleted. Structures that aren't linked are left alone. The scorecards of a definition are marked as outdated if
+// its tree changed.
+func syncLinkedStructures(ctx context.Context, tx *sqlx.Tx, programID int) error {
+	var structures []*model.ScorecardStructure
+	err := tx.SelectContext(ctx, &structures, `
+		SELECT id, definition_id, parent_id, title, syllabus_id, position, is_linked
+		FROM scorecard_structures
+		WHERE program_id = ?
+		ORDER BY position, rowid
+	`, programID)
+	if err != nil {
+		return err
+	}
+
+	var syllabuses []*struct {
+		model.BaseSyllabus
+		ParentID *int `db:"parent_id"`
+	}
+	err = tx.SelectContext(ctx, &syllabuses, `
+		SELECT s.id, s.parent_id, s.title
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		ORDER BY s.position, s.rowid
+	`, programID)
+	if err != nil {
+		return err
+	}
+
+	titles := make(map[int]string, len(syllabuses))
+	syllabusChildren := make(map[int][]int)
+	for _, syllabus := range syllabuses {
+		titles[syllabus.ID] = syllabus.Title
+		if syllabus.ParentID != nil {
+			syllabusChildren[*syllabus.ParentID] = append(syllabusChildren[*syllabus.ParentID], syllabus.ID)
+		}
+	}
+
+	m := make(map[int]*model.ScorecardStructure, len(structures))
+	children := make(map[int][]*model.ScorecardStructure)
+	for _, structure := range structures {
+		m[structure.ID] = structure
+		if structure.ParentID != nil {
+			children[*structure.ParentID] = append(children[*structure.ParentID], structure)
+		}
+	}
+
+	var deleteIds, definitionIds []int
+	for _, root := range structures {
+		// A root is a linked structure that isn't under another linked structure
+		if !root.IsLinked || root.SyllabusID == nil || (root.ParentID != nil && m[*root.ParentID].IsLinked) {
+			continue
+		}
+
+		// Duplicates of the same syllabus aren't in linked, so they are deleted along with the other structures that
+		// aren't visited
+		var linkedIds []int
+		linked := make(map[int]int) // syllabusID -> structureID
+		var walk func(structureID int)
+		walk = func(structureID int) {
+			for _, child := range children[structureID] {
+				if child.IsLinked && child.SyllabusID != nil {
+					linkedIds = append(linkedIds, child.ID)
+					if _, ok := linked[*child.SyllabusID]; !ok {
+						linked[*child.SyllabusID] = child.ID
+					}
+					walk(child.ID)
+				}
+			}
+		}
+		walk(root.ID)
+
+		if title := titles[*root.SyllabusID]; root.Title != title {
+			if _, err := tx.ExecContext(ctx, `UPDATE scorecard_structures SET title = ? WHERE id = ?`, title, root.ID); err != nil {
+				return err
+			}
+		}
+
+		var isChanged bool
+		isVisited := make(map[int]bool)
+		var syncChildren func(syllabusID, parentID int) error
+		syncChildren = func(syllabusID, parentID int) error {
+			for i, childID := range syllabusChildren[syllabusID] {
+				structureID, ok := linked[childID]
+				if !ok {
+					err := tx.QueryRowContext(ctx, `
+						INSERT INTO scorecard_structures (program_id, definition_id, parent_id, title, syllabus_id, position, is_linked)
+						VALUES (?, ?, ?, ?, ?, ?, TRUE)
+						RETURNING id
+					`, programID, root.DefinitionID, parentID, titles[childID], childID, i).Scan(&structureID)
+					if err != nil {
+						return err
+					}
+					isChanged = true
+				} else if structure := m[structureID]; *structure.ParentID != parentID || structure.Title != titles[childID] || structure.Position != i {
+					// The order of the children follows the syllabuses
+					_, err := tx.ExecContext(ctx, `
+						UPDATE scorecard_structures
+						SET parent_id = ?, title = ?, position = ?
+						WHERE id = ?
+					`, parentID, titles[childID], i, structureID)
+					if err != nil {
+						return err
+					}
+					isChanged = isChanged || *structure.ParentID != parentID
+				}
+				isVisited[structureID] = true
+
+				if err := syncChildren(childID, structureID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := syncChildren(*root.SyllabusID, root.ID); err != nil {
+			return err
+		}
+
+		for _, structureID := range linkedIds {
+			if !isVisited[structureID] {
+				deleteIds = append(deleteIds, structureID)
+				isChanged = true
+			}
+		}
+
+		// Only the scorecards of the definition the root belongs to are generated from the changed tree
+		if isChanged && !slices.Contains(definitionIds, root.DefinitionID) {
+			definitionIds = append(definitionIds, root.DefinitionID)
+		}
+	}
+
+	if len(deleteIds) > 0 {
+		query, args, err := sqlx.In(`DELETE FROM scorecard_structures WHERE id IN (?)`, deleteIds)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	if len(definitionIds) > 0 {
+		query, args, err := sqlx.In(`UPDATE scorecards SET is_outdated = TRUE WHERE definition_id IN (?)`, definitionIds)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getStructureLink returns whether the structure is linked and whether it's under another linked structure, in which
+// case it's kept in sync by its root and can't be changed directly
+func (h *Handler) getStructureLink(ctx context.Context, structureID int) (bool, bool, error) {
+	var isLinked, isLinkedChild bool
+	err := h.db.QueryRowContext(ctx, `
+		SELECT ss.is_linked, ss.is_linked AND COALESCE(p.is_linked, FALSE)
+		FROM scorecard_structures ss
+		LEFT JOIN scorecard_structures p ON p.id = ss.parent_id
+		WHERE ss.id = ?
+	`, structureID).Scan(&isLinked, &isLinkedChild)
+	if err != nil && err != sql.ErrNoRows {
+		log.Error().Err(err).Msg("scorecard.getStructureLink")
+		return false, false, constant.ErrInternalServerError
+	}
+	return isLinked, isLinkedChild, nil
+}
+
 func (h *Handler) saveScorecardStructure(c *fiber.Ctx) error {
 	var result struct {
 		Success bool `json:"success"`
@@ -269,9 +552,11 @@ func (h *Handler) saveScorecardStructure(c *fiber.Ctx) error {
 
 	programID, _ := c.ParamsInt("programId")
 
+	// A structure without a weight counts the same as its siblings, or keeps its weight when it's updated
 	var body struct {
-		ParentID *int   `json:"parentId"`
-		Title    string `json:"title"`
+		ParentID *int     `json:"parentId"`
+		Title    string   `json:"title"`
+		Weight   *float64 `json:"weight"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		log.Error().Err(err).Msg("scorecard.saveScorecardStructure")
@@ -279,29 +564,94 @@ func (h *Handler) saveScorecardStructure(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
 
+	if body.Weight != nil && *body.Weight <= 0 {
+		result.Error = fiber.Map{"code": "INVALID_WEIGHT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
 	structureID, _ := c.ParamsInt("structureId")
 
 	if structureID == 0 {
-		_, err := h.db.ExecContext(c.UserContext(), `
-			INSERT INTO scorecard_structures (program_id, parent_id, title)
-			VALUES (?, ?, ?)
-		`, programID, body.ParentID, body.Title)
+		definitionID, err := h.getScorecardDefinitionID(c)
+		if err != nil {
+			if err == constant.ErrNotFound {
+				result.Error = constant.RespNotFound
+				return c.Status(fiber.StatusNotFound).JSON(result)
+			}
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		// New structures are added after their siblings
+		_, err = h.db.ExecContext(c.UserContext(), `
+			INSERT INTO scorecard_structures (program_id, definition_id, parent_id, title, position, weight)
+			VALUES (?, ?, ?, ?, (
+			  SELECT COALESCE(MAX(position) + 1, 0)
+			  FROM scorecard_structures
+			  WHERE definition_id = ?
+			    AND parent_id IS ?
+			), COALESCE(?, 1))
+		`, programID, definitionID, body.ParentID, body.Title, definitionID, body.ParentID, body.Weight)
 		if err != nil {
 			log.Error().Err(err).Msg("scorecard.saveScorecardStructure")
 			result.Error = constant.RespInternalServerError
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
 	} else if structureID > 0 {
-		_, err := h.db.ExecContext(c.UserContext(), `
+		isLinked, isLinkedChild, err := h.getStructureLink(c.UserContext(), structureID)
+		if err == nil && isLinked && !isLinkedChild && body.ParentID != nil {
+			// A linked root can be moved, but not into another linked subtree
+			isLinkedChild, _, err = h.getStructureLink(c.UserContext(), *body.ParentID)
+		}
+		if err != nil {
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if isLinkedChild {
+			result.Error = fiber.Map{"code": "STRUCTURE_IS_LINKED"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+
+		tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+		// The weight changes the scores, so the scorecards of the definition are outdated before it's replaced
+		if body.Weight != nil {
+			_, err := tx.ExecContext(c.UserContext(), `
+				UPDATE scorecards
+				SET is_outdated = TRUE
+				WHERE definition_id = (SELECT definition_id FROM scorecard_structures WHERE id = ? AND weight != ?)
+			`, structureID, body.Weight)
+			if err != nil {
+				tx.Rollback()
+				log.Error().Err(err).Msg("scorecard.saveScorecardStructure")
+				result.Error = constant.RespInternalServerError
+				return c.Status(fiber.StatusInternalServerError).JSON(result)
+			}
+		}
+
+		// The title of a linked structure follows its syllabus. A structure that changes its parent is added after its
+		// new siblings.
+		_, err = tx.ExecContext(c.UserContext(), `
 			UPDATE scorecard_structures
-			SET parent_id = ?, title = ?
+			SET parent_id = ?,
+			  title = CASE WHEN is_linked THEN title ELSE ? END,
+			  position = CASE WHEN parent_id IS ? THEN position ELSE (
+			    SELECT COALESCE(MAX(ss.position) + 1, 0)
+			    FROM scorecard_structures ss
+			    WHERE ss.definition_id = scorecard_structures.definition_id
+			      AND ss.parent_id IS ?
+			  ) END,
+			  weight = COALESCE(?, weight)
 			WHERE id = ?
-		`, body.ParentID, body.Title, structureID)
+		`, body.ParentID, body.Title, body.ParentID, body.ParentID, body.Weight, structureID)
 		if err != nil {
+			tx.Rollback()
 			log.Error().Err(err).Msg("scorecard.saveScorecardStructure")
 			result.Error = constant.RespInternalServerError
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
+
+		tx.Commit()
 	} else {
 		return c.Status(fiber.StatusBadRequest).JSON(result)
 	}
@@ -310,6 +660,182 @@ func (h *Handler) saveScorecardStructure(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
+// placeSibling returns the siblings in their new order after id is placed right before beforeID, right after afterID
+// or, if neither is given, after every sibling. siblings shouldn't contain id. ok is false if the given sibling isn't in
+// siblings.
+func placeSibling(siblings []int, id int, beforeID, afterID *int) ([]int, bool) {
+	ids := make([]int, 0, len(siblings)+1)
+	isPlaced := false
+	for _, siblingID := range siblings {
+		if beforeID != nil && siblingID == *beforeID {
+			ids = append(ids, id)
+			isPlaced = true
+		}
+		ids = append(ids, siblingID)
+		if afterID != nil && siblingID == *afterID {
+			ids = append(ids, id)
+			isPlaced = true
+		}
+	}
+	if !isPlaced {
+		if beforeID != nil || afterID != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// moveScorecardStructure moves the structure under parentId, or to the roots if it's null, and places it before
+// beforeId or after afterId. The siblings under the new parent are renumbered.
+func (h *Handler) moveScorecardStructure(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	structureID, _ := c.ParamsInt("structureId")
+
+	var body struct {
+		ParentID *int `json:"parentId"`
+		BeforeID *int `json:"beforeId"`
+		AfterID  *int `json:"afterId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if structureID == 0 || (body.BeforeID != nil && body.AfterID != nil) {
+		result.Error = fiber.Map{"code": "INVALID_SIBLING_ID"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	isLinked, isLinkedChild, err := h.getStructureLink(c.UserContext(), structureID)
+	if err == nil && isLinked && !isLinkedChild && body.ParentID != nil {
+		// A linked root can be moved, but not into another linked subtree
+		isLinkedChild, _, err = h.getStructureLink(c.UserContext(), *body.ParentID)
+	}
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	if isLinkedChild {
+		result.Error = fiber.Map{"code": "STRUCTURE_IS_LINKED"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	var oldParentID *int
+	var definitionID int
+	err = tx.QueryRowContext(c.UserContext(), `
+		SELECT parent_id, definition_id
+		FROM scorecard_structures
+		WHERE id = ?
+	`, structureID).Scan(&oldParentID, &definitionID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if body.ParentID != nil {
+		// The structure can't be moved into another definition, into itself or into one of its descendants
+		var isValid bool
+		err := tx.QueryRowContext(c.UserContext(), `
+			WITH RECURSIVE t AS (
+			  SELECT id
+			  FROM scorecard_structures
+			  WHERE id = ?
+			  UNION
+			  SELECT ss.id
+			  FROM scorecard_structures ss
+			  JOIN t ON ss.parent_id = t.id
+			)
+			SELECT EXISTS (
+			  SELECT id
+			  FROM scorecard_structures
+			  WHERE id = ?
+			    AND definition_id = ?
+			    AND id NOT IN (SELECT id FROM t)
+			)
+		`, structureID, *body.ParentID, definitionID).Scan(&isValid)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if !isValid {
+			tx.Rollback()
+			result.Error = fiber.Map{"code": "INVALID_PARENT_ID"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+	}
+
+	var siblings []int
+	err = tx.SelectContext(c.UserContext(), &siblings, `
+		SELECT id
+		FROM scorecard_structures
+		WHERE definition_id = ?
+		  AND parent_id IS ?
+		  AND id != ?
+		ORDER BY position, rowid
+	`, definitionID, body.ParentID, structureID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	ids, ok := placeSibling(siblings, structureID, body.BeforeID, body.AfterID)
+	if !ok {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "INVALID_SIBLING_ID"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	_, err = tx.ExecContext(c.UserContext(), `UPDATE scorecard_structures SET parent_id = ? WHERE id = ?`, body.ParentID, structureID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	positions := make([][2]int, len(ids))
+	for i, id := range ids {
+		positions[i] = [2]int{id, i}
+	}
+	if err := relinkRows(c.UserContext(), tx, "scorecard_structures", "position", positions); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// The order doesn't change the scores, only the parent does
+	isParentChanged := (oldParentID == nil) != (body.ParentID == nil) || (oldParentID != nil && *oldParentID != *body.ParentID)
+	if isParentChanged {
+		_, err := tx.ExecContext(c.UserContext(), `UPDATE scorecards SET is_outdated = TRUE WHERE definition_id = ?`, definitionID)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("scorecard.moveScorecardStructure")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
 func (h *Handler) deleteScorecardStructure(c *fiber.Ctx) error {
 	var result struct {
 		Success bool `json:"success"`
@@ -319,12 +845,39 @@ func (h *Handler) deleteScorecardStructure(c *fiber.Ctx) error {
 	programID, _ := c.ParamsInt("programId")
 	structureID, _ := c.ParamsInt("structureId", -1)
 
+	if structureID > 0 {
+		_, isLinkedChild, err := h.getStructureLink(c.UserContext(), structureID)
+		if err != nil {
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if isLinkedChild {
+			result.Error = fiber.Map{"code": "STRUCTURE_IS_LINKED"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+	}
+
+	// A structureID of 0 deletes every structure of the definition
+	var definitionID int
+	if structureID == 0 {
+		v, err := h.getScorecardDefinitionID(c)
+		if err != nil {
+			if err == constant.ErrNotFound {
+				result.Error = constant.RespNotFound
+				return c.Status(fiber.StatusNotFound).JSON(result)
+			}
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		definitionID = v
+	}
+
 	tx := h.db.MustBeginTx(c.UserContext(), nil)
 
 	_, err := tx.ExecContext(c.UserContext(), `
 		DELETE FROM scorecard_structures
-		WHERE (? = 0 OR id = ?)
-	`, structureID, structureID)
+		WHERE (? = 0 AND definition_id = ?) OR id = ?
+	`, structureID, definitionID, structureID)
 	if err != nil {
 		tx.Rollback()
 		log.Error().Err(err).Msg("scorecard.deleteScorecardStructure")
@@ -333,7 +886,7 @@ func (h *Handler) deleteScorecardStructure(c *fiber.Ctx) error {
 	}
 
 	if structureID == 0 {
-		_, err = tx.ExecContext(c.UserContext(), `DELETE FROM scorecards WHERE program_id = ?`, programID)
+		_, err = tx.ExecContext(c.UserContext(), `DELETE FROM scorecards WHERE definition_id = ?`, definitionID)
 		if err != nil {
 			tx.Rollback()
 			log.Error().Err(err).Msg("scorecard.deleteScorecardStructure")
@@ -358,58 +911,60 @@ func (h *Handler) deleteScorecardStructure(c *fiber.Ctx) error {
 
 func (h *Handler) generateScorecards(c *fiber.Ctx) error {
 	var result struct {
-		Success bool `json:"success"`
-		Error   any  `json:"error"`
-	}
-
-	if stats := h.generator.Stats(); stats.InQueue != 0 {
-		result.Success = true
-		return c.Status(fiber.StatusOK).JSON(result)
+		Success bool                    `json:"success"`
+		Status  scorecard.EnqueueStatus `json:"status"`
+		Error   any                     `json:"error"`
 	}
 
 	programID, _ := c.ParamsInt("programId")
 
 	if scorecardID, _ := c.ParamsInt("scorecardId"); scorecardID > 0 {
-		var userID int
+		var definitionID, userID int
 		err := h.db.QueryRowContext(c.UserContext(), `
-			SELECT user_id
+			SELECT definition_id, user_id
 			FROM scorecards s
 			WHERE program_id = ?
 			  AND id = ?
-		`, programID, scorecardID).Scan(&userID)
+		`, programID, scorecardID).Scan(&definitionID, &userID)
 		if err != nil {
 			log.Error().Err(err).Msg("scorecard.generateScorecards")
 			result.Error = constant.RespInternalServerError
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
-		h.generator.Enqueue(c.UserContext(), programID, userID, scorecardID)
+		result.Status = h.generator.Enqueue(c.UserContext(), programID, definitionID, userID, scorecardID, scorecard.PriorityHigh)
 	} else {
-		rows, err := h.db.QueryContext(c.UserContext(), `
-			SELECT DISTINCT us.user_id, COALESCE(s2.id, 0) AS scorecard_id
-			FROM syllabus_structures ss
-			JOIN syllabuses s ON s.structure_id = ss.id
-			JOIN user_scores us ON us.syllabus_id = s.id
-			LEFT JOIN scorecards s2 ON s2.user_id = us.user_id
-			WHERE ss.program_id = ?
-		`, programID)
+		definitionID, err := h.getScorecardDefinitionID(c)
 		if err != nil {
-			log.Error().Err(err).Msg("scorecard.generateScorecards")
+			if err == constant.ErrNotFound {
+				result.Error = constant.RespNotFound
+				return c.Status(fiber.StatusNotFound).JSON(result)
+			}
 			result.Error = constant.RespInternalServerError
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
-		defer rows.Close()
 
-		for rows.Next() {
-			var userID, scorecardID int
-			if err := rows.Scan(&userID, &scorecardID); err != nil {
-				log.Error().Err(err).Msg("scorecard.generateScorecards")
-				result.Error = constant.RespInternalServerError
-				return c.Status(fiber.StatusInternalServerError).JSON(result)
-			}
-			h.generator.Enqueue(c.UserContext(), programID, userID, scorecardID)
+		status, err := scorecard.EnqueueProgram(c.UserContext(), h.db, h.generator, programID, definitionID)
+		if err != nil {
+			log.Error().Err(err).Msg("scorecard.generateScorecards")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
+		result.Status = status
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) cancelScorecardsGeneration(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
 	}
 
+	programID, _ := c.ParamsInt("programId")
+	h.generator.Cancel(programID)
+
 	result.Success = true
 	return c.Status(fiber.StatusOK).JSON(result)
 }
@@ -422,12 +977,22 @@ func (h *Handler) scorecards(c *fiber.Ctx) error {
 	}
 	result.Nodes = []*model.Scorecard{}
 
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
 	rows, err := h.db.QueryxContext(c.UserContext(), `
-		SELECT id, user_id, score, is_outdated, generated_at
+		SELECT id, user_id, score, published_score, is_outdated, is_published, generated_at, published_at
 		FROM scorecards
-		WHERE program_id = ?
+		WHERE definition_id = ?
 		ORDER BY rowid ASC
-	`, c.Params("programId"))
+	`, definitionID)
 	if err != nil {
 		log.Error().Err(err).Msg("scorecard.scorecards")
 		result.Error = constant.RespInternalServerError
@@ -435,7 +1000,7 @@ func (h *Handler) scorecards(c *fiber.Ctx) error {
 	}
 	defer rows.Close()
 
-	var userIds []int
+	var scorecardIds, userIds []int
 	for rows.Next() {
 		var node model.Scorecard
 		if err := rows.StructScan(&node); err != nil {
@@ -444,14 +1009,16 @@ func (h *Handler) scorecards(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
 		result.Nodes = append(result.Nodes, &node)
+		scorecardIds = append(scorecardIds, node.ID)
 		userIds = append(userIds, node.UserID)
 	}
 	c.Set("X-Total-Count", strconv.Itoa(len(result.Nodes)))
 
 	users, _ := h.getUsers(c.UserContext(), userIds)
+	inQueue := h.generator.InQueue(scorecardIds)
 	for _, node := range result.Nodes {
 		node.User = users[node.UserID]
-		node.IsInQueue = h.generator.IsInQueue(node.ID)
+		node.IsInQueue = inQueue[node.ID]
 	}
 
 	result.Stats = h.generator.Stats()
@@ -459,7 +1026,9 @@ func (h *Handler) scorecards(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
-func (h *Handler) scorecard(c *fiber.Ctx) error {
+// getScorecard returns the published snapshot of the scorecard, or the draft compared with the published snapshot if
+// isDraft is true. A scorecard that has never been published doesn't have a snapshot.
+func (h *Handler) getScorecard(c *fiber.Ctx, isDraft bool) error {
 	var result struct {
 		Scorecard *model.Scorecard `json:"scorecard"`
 		Error     any              `json:"error"`
@@ -467,22 +1036,28 @@ func (h *Handler) scorecard(c *fiber.Ctx) error {
 
 	scorecardID, _ := c.ParamsInt("scorecardId")
 
+	score := "published_score"
+	if isDraft {
+		score = "score"
+	}
+
 	scorecard := model.Scorecard{
 		Items:     []*model.ScorecardItem{},
 		IsInQueue: h.generator.IsInQueue(scorecardID),
 	}
-	err := h.db.QueryRowxContext(c.UserContext(), `
-		SELECT id, user_id, score, is_outdated, generated_at
+	err := h.db.QueryRowxContext(c.UserContext(), fmt.Sprintf(`
+		SELECT id, user_id, %s AS score, published_score, is_outdated, is_published, generated_at, published_at
 		FROM scorecards
 		WHERE program_id = ?
 		  AND id = ?
-	`, c.Params("programId"), scorecardID).StructScan(&scorecard)
+		  AND %s IS NOT NULL
+	`, score, score), c.Params("programId"), scorecardID).StructScan(&scorecard)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			result.Error = constant.RespNotFound
 			return c.Status(fiber.StatusNotFound).JSON(result)
 		}
-		log.Error().Err(err).Msg("scorecard.scorecard")
+		log.Error().Err(err).Msg("scorecard.getScorecard")
 		result.Error = constant.RespInternalServerError
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
@@ -503,13 +1078,27 @@ func (h *Handler) scorecard(c *fiber.Ctx) error {
 	go func() {
 		defer wg.Done()
 
-		rows, err := h.db.QueryxContext(c.UserContext(), `
-			SELECT structure_id, score
+		// Only the feedback is included, the note is kept for the instructors. The draft shows the current feedback,
+		// while the published scorecard shows the one it was published with.
+		query, args := `
+			SELECT structure_id, published_score AS score, published_score, published_feedback AS feedback
 			FROM scorecard_items
 			WHERE scorecard_id = ?
-		`, result.Scorecard.ID)
+			  AND published_score IS NOT NULL
+		`, []any{result.Scorecard.ID}
+		if isDraft {
+			query, args = `
+				SELECT si.structure_id, si.score, si.published_score, us.feedback
+				FROM scorecard_items si
+				JOIN scorecard_structures ss ON ss.id = si.structure_id
+				LEFT JOIN user_scores us ON us.user_id = ? AND us.syllabus_id = ss.syllabus_id
+				WHERE si.scorecard_id = ?
+			`, []any{result.Scorecard.UserID, result.Scorecard.ID}
+		}
+
+		rows, err := h.db.QueryxContext(c.UserContext(), query, args...)
 		if err != nil {
-			log.Error().Err(err).Msg("scorecard.scorecard")
+			log.Error().Err(err).Msg("scorecard.getScorecard")
 			return
 		}
 		defer rows.Close()
@@ -517,7 +1106,7 @@ func (h *Handler) scorecard(c *fiber.Ctx) error {
 		for rows.Next() {
 			var node model.ScorecardItem
 			if err := rows.StructScan(&node); err != nil {
-				log.Error().Err(err).Msg("scorecard.scorecard")
+				log.Error().Err(err).Msg("scorecard.getScorecard")
 				continue
 			}
 			result.Scorecard.Items = append(result.Scorecard.Items, &node)
@@ -528,3 +1117,272 @@ func (h *Handler) scorecard(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(result)
 }
+
+func (h *Handler) scorecard(c *fiber.Ctx) error {
+	return h.getScorecard(c, false)
+}
+
+func (h *Handler) scorecardDraft(c *fiber.Ctx) error {
+	return h.getScorecard(c, true)
+}
+
+// publishScorecards replaces the published snapshot with the draft of every scorecard in the definition that has an
+// unpublished draft, or only of the scorecard in the path
+func (h *Handler) publishScorecards(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Count   int  `json:"count"`
+		Error   any  `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	scorecardID, _ := c.ParamsInt("scorecardId")
+
+	// A single scorecard is found by its id, the definition is only needed to publish all of them
+	var definitionID int
+	if scorecardID == 0 {
+		v, err := h.getScorecardDefinitionID(c)
+		if err != nil {
+			if err == constant.ErrNotFound {
+				result.Error = constant.RespNotFound
+				return c.Status(fiber.StatusNotFound).JSON(result)
+			}
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		definitionID = v
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	_, err := tx.ExecContext(c.UserContext(), `
+		UPDATE scorecard_items
+		SET published_score = score, published_feedback = (
+		  SELECT us.feedback
+		  FROM scorecards s
+		  JOIN scorecard_structures ss ON ss.id = scorecard_items.structure_id
+		  JOIN user_scores us ON us.user_id = s.user_id AND us.syllabus_id = ss.syllabus_id
+		  WHERE s.id = scorecard_items.scorecard_id
+		)
+		WHERE scorecard_id IN (
+		  SELECT id
+		  FROM scorecards
+		  WHERE program_id = ?
+		    AND (id = ? OR (? = 0 AND definition_id = ?))
+		    AND is_published = FALSE
+		)
+	`, programID, scorecardID, scorecardID, definitionID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.publishScorecards")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	res, err := tx.ExecContext(c.UserContext(), `
+		UPDATE scorecards
+		SET published_score = score, is_published = TRUE, published_at = CURRENT_TIMESTAMP
+		WHERE program_id = ?
+		  AND (id = ? OR (? = 0 AND definition_id = ?))
+		  AND is_published = FALSE
+	`, programID, scorecardID, scorecardID, definitionID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("scorecard.publishScorecards")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	n, _ := res.RowsAffected()
+	result.Success = true
+	result.Count = int(n)
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+type scorecardStructureNode struct {
+	ID    int
+	Title string
+	Path  string
+	Depth int
+}
+
+type scorecardRow struct {
+	ID          int
+	Name        string
+	Score       float64
+	IsOutdated  bool            `db:"is_outdated"`
+	PublishedAt model.Time      `db:"published_at"`
+	Items       map[int]float64 `db:"-"` // structureID -> score
+}
+
+// getScorecardRows returns the scorecard structures of the definition in the order of the tree, so every structure is
+// placed right after its parent, along with the published scorecards and their items. A scorecardID limits the
+// scorecards to a single one.
+func (h *Handler) getScorecardRows(ctx context.Context, definitionID, scorecardID int) ([]*scorecardStructureNode, []*scorecardRow, error) {
+	var structures []*model.ScorecardStructure
+	err := h.db.SelectContext(ctx, &structures, `
+		SELECT id, parent_id, title, syllabus_id
+		FROM scorecard_structures
+		WHERE definition_id = ?
+		ORDER BY position, rowid
+	`, definitionID)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.getScorecardRows")
+		return nil, nil, constant.ErrInternalServerError
+	}
+
+	children := make(map[int][]*model.ScorecardStructure)
+	for _, structure := range structures {
+		parentID := 0
+		if structure.ParentID != nil {
+			parentID = *structure.ParentID
+		}
+		children[parentID] = append(children[parentID], structure)
+	}
+
+	var nodes []*scorecardStructureNode
+	var walk func(parentID int, parentPath string, depth int)
+	walk = func(parentID int, parentPath string, depth int) {
+		for _, structure := range children[parentID] {
+			path := structure.Title
+			if parentPath != "" {
+				path = parentPath + " / " + structure.Title
+			}
+			nodes = append(nodes, &scorecardStructureNode{structure.ID, structure.Title, path, depth})
+			walk(structure.ID, path, depth+1)
+		}
+	}
+	walk(0, "", 0)
+
+	var scorecards []*scorecardRow
+	err = h.db.SelectContext(ctx, &scorecards, `
+		SELECT s.id, u.name, s.published_score AS score, s.is_outdated, s.published_at
+		FROM scorecards s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.definition_id = ?
+		  AND (? = 0 OR s.id = ?)
+		  AND s.published_score IS NOT NULL
+		ORDER BY s.rowid
+	`, definitionID, scorecardID, scorecardID)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.getScorecardRows")
+		return nil, nil, constant.ErrInternalServerError
+	}
+
+	m := make(map[int]*scorecardRow, len(scorecards))
+	for _, scorecard := range scorecards {
+		scorecard.Items = make(map[int]float64)
+		m[scorecard.ID] = scorecard
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT si.scorecard_id, si.structure_id, si.published_score
+		FROM scorecard_items si
+		JOIN scorecards s ON s.id = si.scorecard_id
+		WHERE s.definition_id = ?
+		  AND (? = 0 OR s.id = ?)
+		  AND si.published_score IS NOT NULL
+	`, definitionID, scorecardID, scorecardID)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.getScorecardRows")
+		return nil, nil, constant.ErrInternalServerError
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scorecardID, structureID int
+		var score float64
+		if err := rows.Scan(&scorecardID, &structureID, &score); err != nil {
+			log.Error().Err(err).Msg("scorecard.getScorecardRows")
+			return nil, nil, constant.ErrInternalServerError
+		}
+		if scorecard, ok := m[scorecardID]; ok {
+			scorecard.Items[structureID] = score
+		}
+	}
+
+	return nodes, scorecards, nil
+}
+
+// ?format csv|xlsx
+// ?layout wide|long
+
+// exportScorecards writes one row per user with a column for every scorecard structure by default. The long layout
+// writes one row per user per structure instead, the overall score is written as a row without a structure.
+func (h *Handler) exportScorecards(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	format := spreadsheet.Format(c.Query("format", string(spreadsheet.FormatCSV)))
+	if format != spreadsheet.FormatCSV && format != spreadsheet.FormatXLSX {
+		result.Error = fiber.Map{"code": "UNSUPPORTED_FORMAT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	layout := c.Query("layout", "wide")
+	if layout != "wide" && layout != "long" {
+		result.Error = fiber.Map{"code": "INVALID_LAYOUT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	structures, scorecards, err := h.getScorecardRows(c.UserContext(), definitionID, 0)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var table [][]any
+	if layout == "long" {
+		table = append(table, []any{"User", "Structure", "Score", "Outdated", "Published At"})
+		for _, scorecard := range scorecards {
+			publishedAt := scorecard.PublishedAt.Format(time.DateTime)
+			table = append(table, []any{scorecard.Name, nil, scorecard.Score, scorecard.IsOutdated, publishedAt})
+			for _, structure := range structures {
+				if score, ok := scorecard.Items[structure.ID]; ok {
+					table = append(table, []any{scorecard.Name, structure.Path, score, scorecard.IsOutdated, publishedAt})
+				}
+			}
+		}
+	} else {
+		header := []any{"User", "Score"}
+		for _, structure := range structures {
+			header = append(header, structure.Path)
+		}
+		table = append(table, append(header, "Outdated", "Published At"))
+
+		for _, scorecard := range scorecards {
+			row := []any{scorecard.Name, scorecard.Score}
+			for _, structure := range structures {
+				if score, ok := scorecard.Items[structure.ID]; ok {
+					row = append(row, score)
+				} else {
+					row = append(row, nil)
+				}
+			}
+			table = append(table, append(row, scorecard.IsOutdated, scorecard.PublishedAt.Format(time.DateTime)))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := spreadsheet.Write(&buf, format, table); err != nil {
+		log.Error().Err(err).Msg("scorecard.exportScorecards")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	c.Attachment("scorecards." + string(format))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
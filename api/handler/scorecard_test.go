@@ -10,6 +10,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/brantem/scorecard/model"
+	sc "github.com/brantem/scorecard/scorecard"
 	"github.com/brantem/scorecard/testutil/db"
 	"github.com/brantem/scorecard/testutil/middleware"
 	"github.com/brantem/scorecard/testutil/scorecard"
@@ -68,8 +69,9 @@ func Test_scorecardStructures(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		expectScorecardDefinitionID(mock, 3)
 		mock.ExpectQuery(`SELECT COUNT\(id\) FROM scorecard_structures`).
-			WithArgs(1).
+			WithArgs(3).
 			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
 		app := fiber.New()
@@ -87,13 +89,14 @@ func Test_scorecardStructures(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		expectScorecardDefinitionID(mock, 3)
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(1, 1, 1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "title", "syllabus_id"}).AddRow(1, nil, "Structure 1", 1))
+			WithArgs(3, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "title", "syllabus_id", "weight"}).AddRow(1, nil, "Structure 1", 1, 2))
 
 		mock.ExpectQuery("SELECT .+ FROM syllabuses .+ WHERE s.id IN (?)").
 			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "is_assignment"}).AddRow(1, "Syllabus 1", false))
+			WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "code", "is_assignment"}).AddRow(1, "Syllabus 1", "Description 1", "S1", false))
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -105,7 +108,7 @@ func Test_scorecardStructures(t *testing.T) {
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 		assert.Equal("1", resp.Header.Get("X-Total-Count"))
 		body, _ := io.ReadAll(resp.Body)
-		assert.Equal(`{"nodes":[{"id":1,"parentId":null,"title":"Structure 1","syllabus":{"id":1,"title":"Syllabus 1","isAssignment":false}}],"error":null}`, string(body))
+		assert.Equal(`{"nodes":[{"id":1,"parentId":null,"title":"Structure 1","syllabus":{"id":1,"title":"Syllabus 1","description":"Description 1","code":"S1","isAssignment":false},"position":0,"weight":2,"isLinked":false}],"error":null}`, string(body))
 	})
 }
 
@@ -115,6 +118,7 @@ func Test_copySyllabusesIntoStructures(t *testing.T) {
 	db, mock := db.New()
 	h := New(db, nil)
 
+	expectScorecardDefinitionID(mock, 3)
 	mock.ExpectQuery(`.+ SELECT s.\* FROM syllabuses`).
 		WithArgs(1, 0, 0).
 		WillReturnRows(
@@ -125,9 +129,13 @@ func Test_copySyllabusesIntoStructures(t *testing.T) {
 
 	mock.ExpectBegin()
 
+	mock.ExpectQuery("SELECT COALESCE\\(MAX\\(position\\) \\+ 1, 0\\) FROM scorecard_structures").
+		WithArgs(3, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow(2))
+
 	structureIds := [2]int{1, 2}
 	mock.ExpectQuery("INSERT INTO scorecard_structures").
-		WithArgs(1, syllabusIds[0], syllabusIds[1]).
+		WithArgs(1, 3, syllabusIds[0], syllabusIds[1]).
 		WillReturnRows(
 			sqlmock.NewRows([]string{"id", "syllabus_id"}).
 				AddRow(structureIds[0], syllabusIds[0]).
@@ -135,14 +143,14 @@ func Test_copySyllabusesIntoStructures(t *testing.T) {
 		)
 
 	mock.ExpectExec(fmt.Sprintf(
-		`WITH .+ \(%d, NULL\), \(%d, %d\) .+ UPDATE scorecard_structures`,
+		`WITH .+ \(%d, NULL, 2\), \(%d, %d, 0\) .+ UPDATE scorecard_structures SET parent_id = t.parent_id, position = .+ \+ t.shift`,
 		structureIds[0],
 		structureIds[1], structureIds[0],
 	)).
 		WillReturnResult(sqlmock.NewResult(0, 2))
 
 	mock.ExpectExec("UPDATE scorecards").
-		WithArgs(1).
+		WithArgs(3).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	mock.ExpectCommit()
@@ -160,6 +168,123 @@ func Test_copySyllabusesIntoStructures(t *testing.T) {
 	assert.Equal(t, `{"success":true,"error":null}`, string(body))
 }
 
+func Test_copySyllabusesIntoStructures_linked(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	expectScorecardDefinitionID(mock, 3)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO scorecard_structures .+ NOT EXISTS").
+		WithArgs(1, 3, nil, 3, nil, 1, 2, 2, 3).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "definition_id", "parent_id", "title", "syllabus_id", "is_linked"}).
+				AddRow(1, 3, nil, "Syllabus 2", 2, true),
+		)
+	mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "title"}).
+				AddRow(2, nil, "Syllabus 2").
+				AddRow(3, 2, "Syllabus 3"),
+		)
+	mock.ExpectQuery("INSERT INTO scorecard_structures").
+		WithArgs(1, 3, 1, "Syllabus 3", 3, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("UPDATE scorecards").
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/copy/2", strings.NewReader(`{"parentId":null,"isLinked":true}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+}
+
+func Test_syncLinkedStructures(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("unchanged", func(t *testing.T) {
+		db, mock := db.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "definition_id", "parent_id", "title", "syllabus_id", "is_linked"}).
+					AddRow(1, 3, nil, "Syllabus 1", 1, true).
+					AddRow(2, 3, 1, "Syllabus 2", 2, true).
+					AddRow(3, 3, nil, "Structure 3", nil, false),
+			)
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "title"}).
+					AddRow(1, nil, "Syllabus 1").
+					AddRow(2, 1, "Syllabus 2"),
+			)
+
+		tx := db.MustBegin()
+		assert.Nil(syncLinkedStructures(context.Background(), tx, 1))
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		db, mock := db.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "definition_id", "parent_id", "title", "syllabus_id", "is_linked"}).
+					AddRow(1, 3, nil, "Syllabus 1", 1, true).
+					AddRow(2, 3, 1, "Old", 2, true).        // renamed
+					AddRow(3, 3, 1, "Syllabus 9", 9, true). // moved out of the subtree
+					AddRow(4, 3, 1, "Syllabus 2", 2, true). // duplicate
+					AddRow(5, 3, 1, "Structure 5", nil, false).
+					AddRow(7, 4, nil, "Syllabus 9", 9, true), // unchanged in another definition
+			)
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "title"}).
+					AddRow(1, nil, "Syllabus 1a").
+					AddRow(2, 1, "Syllabus 2").
+					AddRow(3, 1, "Syllabus 3").
+					AddRow(9, nil, "Syllabus 9"),
+			)
+		mock.ExpectExec("UPDATE scorecard_structures SET title = \\? WHERE id = \\?").
+			WithArgs("Syllabus 1a", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE scorecard_structures SET parent_id = \\?, title = \\?, position = \\?").
+			WithArgs(1, "Syllabus 2", 0, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").
+			WithArgs(1, 3, 1, "Syllabus 3", 3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectExec("DELETE FROM scorecard_structures WHERE id IN \\(\\?, \\?\\)").
+			WithArgs(3, 4).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE scorecards SET is_outdated = TRUE WHERE definition_id IN \\(\\?\\)").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		tx := db.MustBegin()
+		assert.Nil(syncLinkedStructures(context.Background(), tx, 1))
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+}
+
 func Test_saveScorecardStructure(t *testing.T) {
 	assert := assert.New(t)
 
@@ -167,8 +292,9 @@ func Test_saveScorecardStructure(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		expectScorecardDefinitionID(mock, 3)
 		mock.ExpectExec("INSERT INTO scorecard_structures").
-			WithArgs(1, nil, "Structure 1").
+			WithArgs(1, 3, nil, "Structure 1", 3, nil, nil).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		app := fiber.New()
@@ -184,13 +310,78 @@ func Test_saveScorecardStructure(t *testing.T) {
 		assert.Equal(`{"success":true,"error":null}`, string(body))
 	})
 
+	t.Run("invalid weight", func(t *testing.T) {
+		h := New(nil, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures", strings.NewReader(`{"title":"Structure 1","weight":0}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_WEIGHT"}}`, string(body))
+	})
+
+	t.Run("linked", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(true, true))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures/2", strings.NewReader(`{"parentId":null,"title":"Structure 2a"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"STRUCTURE_IS_LINKED"}}`, string(body))
+	})
+
+	t.Run("linked root into linked", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(true, false))
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(true, false))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures/2", strings.NewReader(`{"parentId":3,"title":"Structure 2a"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"STRUCTURE_IS_LINKED"}}`, string(body))
+	})
+
 	t.Run("update", func(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE scorecard_structures").
-			WithArgs(nil, "Structure 2a", 2).
+			WithArgs(nil, "Structure 2a", nil, nil, nil, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -204,6 +395,200 @@ func Test_saveScorecardStructure(t *testing.T) {
 		body, _ := io.ReadAll(resp.Body)
 		assert.Equal(`{"success":true,"error":null}`, string(body))
 	})
+
+	t.Run("update weight", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE scorecards SET is_outdated = TRUE .+ AND weight != \\?").
+			WithArgs(2, 2.5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE scorecard_structures .+ weight = COALESCE\\(\\?, weight\\)").
+			WithArgs(nil, "Structure 2a", nil, nil, 2.5, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures/2", strings.NewReader(`{"parentId":null,"title":"Structure 2a","weight":2.5}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_placeSibling(t *testing.T) {
+	assert := assert.New(t)
+
+	ptr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name     string
+		beforeID *int
+		afterID  *int
+		ids      []int
+		ok       bool
+	}{
+		{"last", nil, nil, []int{1, 2, 3, 4}, true},
+		{"before", ptr(1), nil, []int{4, 1, 2, 3}, true},
+		{"after", nil, ptr(2), []int{1, 2, 4, 3}, true},
+		{"after last", nil, ptr(3), []int{1, 2, 3, 4}, true},
+		{"not a sibling", ptr(5), nil, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, ok := placeSibling([]int{1, 2, 3}, 4, tt.beforeID, tt.afterID)
+			assert.Equal(tt.ids, ids)
+			assert.Equal(tt.ok, ok)
+		})
+	}
+}
+
+func Test_moveScorecardStructure(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("invalid parent", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT parent_id, definition_id FROM scorecard_structures").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "definition_id"}).AddRow(nil, 3))
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ SELECT EXISTS").
+			WithArgs(2, 3, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/2/move", strings.NewReader(`{"parentId":3}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_PARENT_ID"}}`, string(body))
+	})
+
+	t.Run("invalid sibling", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT parent_id, definition_id FROM scorecard_structures").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "definition_id"}).AddRow(nil, 3))
+		mock.ExpectQuery("SELECT id FROM scorecard_structures").
+			WithArgs(3, nil, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1).AddRow(3))
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/2/move", strings.NewReader(`{"parentId":null,"beforeId":4}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_SIBLING_ID"}}`, string(body))
+	})
+
+	t.Run("invalid weight", func(t *testing.T) {
+		h := New(nil, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures", strings.NewReader(`{"title":"Structure 1","weight":0}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_WEIGHT"}}`, string(body))
+	})
+
+	t.Run("linked", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(true, true))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/2/move", strings.NewReader(`{"parentId":null}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"STRUCTURE_IS_LINKED"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT parent_id, definition_id FROM scorecard_structures").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"parent_id", "definition_id"}).AddRow(nil, 3))
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ SELECT EXISTS").
+			WithArgs(2, 3, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+		mock.ExpectQuery("SELECT id FROM scorecard_structures").
+			WithArgs(3, 3, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+		mock.ExpectExec("UPDATE scorecard_structures SET parent_id").
+			WithArgs(3, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(`WITH .+ \(4, 0\), \(2, 1\), \(5, 2\) .+ UPDATE scorecard_structures SET position`).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/2/move", strings.NewReader(`{"parentId":3,"afterId":4}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
 }
 
 func Test_deleteScorecardStructure(t *testing.T) {
@@ -213,14 +598,16 @@ func Test_deleteScorecardStructure(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		expectScorecardDefinitionID(mock, 3)
+
 		mock.ExpectBegin()
 
 		mock.ExpectExec("DELETE FROM scorecard_structures").
-			WithArgs(0, 0).
+			WithArgs(0, 3, 0).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		mock.ExpectExec("DELETE FROM scorecards").
-			WithArgs(1).
+			WithArgs(3).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		mock.ExpectCommit()
@@ -237,14 +624,53 @@ func Test_deleteScorecardStructure(t *testing.T) {
 		assert.Equal(`{"success":true,"error":null}`, string(body))
 	})
 
+	t.Run("invalid weight", func(t *testing.T) {
+		h := New(nil, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/scorecards/structures", strings.NewReader(`{"title":"Structure 1","weight":0}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_WEIGHT"}}`, string(body))
+	})
+
+	t.Run("linked", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(true, true))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/structures/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"STRUCTURE_IS_LINKED"}}`, string(body))
+	})
+
 	t.Run("structureId != 0", func(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		mock.ExpectQuery("SELECT ss.is_linked, .+ FROM scorecard_structures ss").
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"is_linked", "is_linked_child"}).AddRow(false, false))
+
 		mock.ExpectBegin()
 
 		mock.ExpectExec("DELETE FROM scorecard_structures").
-			WithArgs(2, 2).
+			WithArgs(2, 0, 2).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		mock.ExpectExec("UPDATE scorecards").
@@ -276,7 +702,7 @@ func Test_generateScorecards(t *testing.T) {
 
 		mock.ExpectQuery("SELECT .+ FROM scorecards").
 			WithArgs(1, 2).
-			WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(3))
+			WillReturnRows(sqlmock.NewRows([]string{"definition_id", "user_id"}).AddRow(4, 3))
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -286,11 +712,13 @@ func Test_generateScorecards(t *testing.T) {
 		resp, _ := app.Test(req)
 		assert.Nil(mock.ExpectationsWereMet())
 		assert.Equal([]int{1}, generator.EnqueueProgramID)
+		assert.Equal([]int{4}, generator.EnqueueDefinitionID)
 		assert.Equal([]int{3}, generator.EnqueueUserID)
 		assert.Equal([]int{2}, generator.EnqueueScorecardID)
+		assert.Equal([]sc.Priority{sc.PriorityHigh}, generator.EnqueuePriority)
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 		body, _ := io.ReadAll(resp.Body)
-		assert.Equal(`{"success":true,"error":null}`, string(body))
+		assert.Equal(`{"success":true,"status":"queued","error":null}`, string(body))
 	})
 
 	t.Run("structureId == 0", func(t *testing.T) {
@@ -298,9 +726,10 @@ func Test_generateScorecards(t *testing.T) {
 		generator := scorecard.NewGenerator()
 		h := New(db, generator)
 
-		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"user_id", "scorecard_id"}).AddRow(1, 1).AddRow(2, 0))
+		expectScorecardDefinitionID(mock, 3)
+		mock.ExpectQuery("SELECT .+ FROM scorecard_definitions d").
+			WithArgs(1, 3, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"definition_id", "user_id", "scorecard_id"}).AddRow(3, 1, 1).AddRow(3, 2, 0))
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -310,22 +739,44 @@ func Test_generateScorecards(t *testing.T) {
 		resp, _ := app.Test(req)
 		assert.Nil(mock.ExpectationsWereMet())
 		assert.Equal([]int{1, 1}, generator.EnqueueProgramID)
+		assert.Equal([]int{3, 3}, generator.EnqueueDefinitionID)
 		assert.Equal([]int{1, 2}, generator.EnqueueUserID)
 		assert.Equal([]int{1, 0}, generator.EnqueueScorecardID)
+		assert.Equal([]sc.Priority{sc.PriorityLow, sc.PriorityLow}, generator.EnqueuePriority)
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 		body, _ := io.ReadAll(resp.Body)
-		assert.Equal(`{"success":true,"error":null}`, string(body))
+		assert.Equal(`{"success":true,"status":"queued","error":null}`, string(body))
 	})
 }
 
+func Test_cancelScorecardsGeneration(t *testing.T) {
+	generator := scorecard.NewGenerator()
+	h := New(nil, generator)
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("DELETE", "/v1/programs/1/scorecards/generate", nil)
+
+	resp, _ := app.Test(req)
+	assert.Equal(t, []int{1}, generator.CancelProgramID)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+}
+
 func Test_scorecards(t *testing.T) {
 	db, mock := db.New()
 	generator := scorecard.NewGenerator()
 	h := New(db, generator)
 
+	expectScorecardDefinitionID(mock, 3)
 	mock.ExpectQuery("SELECT .+ FROM scorecards").
-		WithArgs("1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "score", "is_outdated", "generated_at"}).AddRow(1, 1, 100, false, "2024-01-01 00:00:00"))
+		WithArgs(3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "user_id", "score", "published_score", "is_outdated", "is_published", "generated_at", "published_at"}).
+				AddRow(1, 1, 100, 90, false, false, "2024-01-02 00:00:00", "2024-01-01 00:00:00"),
+		)
 
 	mock.ExpectQuery("SELECT .+ FROM users WHERE id IN (?)").
 		WithArgs(1).
@@ -341,37 +792,263 @@ func Test_scorecards(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	assert.Equal(t, "1", resp.Header.Get("X-Total-Count"))
 	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"stats":{"inQueue":0},"nodes":[{"id":1,"user":{"id":1,"name":"User 1"},"score":100,"items":null,"isOutdated":false,"isInQueue":false,"generatedAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
+	assert.Equal(t, `{"stats":{"inQueue":0},"nodes":[{"id":1,"user":{"id":1,"name":"User 1"},"score":100,"publishedScore":90,"items":null,"isOutdated":false,"isPublished":false,"isInQueue":false,"generatedAt":"2024-01-02T00:00:00Z","publishedAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
 }
 
-func Test_scorecard(t *testing.T) {
-	db, mock := db.New()
-	generator := scorecard.NewGenerator()
-	h := New(db, generator)
+func Test_exportScorecards(t *testing.T) {
+	assert := assert.New(t)
+
+	id := func(v int) *int { return &v }
+
+	// The scorecards and their items are the ones the generator writes for the scores, then published
+	structures := []*sc.Structure{
+		{ID: 1, Weight: 1},
+		{ID: 2, SyllabusID: id(2), Weight: 1},
+		{ID: 3, ParentID: id(1), SyllabusID: id(1), Weight: 1},
+	}
+
+	expectQueries := func(mock sqlmock.Sqlmock) {
+		scorecards := sqlmock.NewRows([]string{"id", "name", "score", "is_outdated", "published_at"})
+		items := sqlmock.NewRows([]string{"scorecard_id", "structure_id", "score"})
+		for i, scores := range []map[int]float64{{1: 90, 2: 70}, {1: 80}} {
+			score, nodes := sc.Reduce(structures, scores)
+			scorecards.AddRow(i+1, fmt.Sprintf("User %d", i+1), score, i == 1, "2024-01-01 00:00:00")
+			for _, node := range nodes {
+				items.AddRow(i+1, node.ID, node.Score)
+			}
+		}
+
+		expectScorecardDefinitionID(mock, 3)
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(3).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "title", "syllabus_id"}).
+					AddRow(1, nil, "Syllabus 1", nil).
+					AddRow(2, nil, "Syllabus 2", 2).
+					AddRow(3, 1, "Assignment 1", 1),
+			)
+		mock.ExpectQuery("SELECT .+ FROM scorecards s JOIN users u").
+			WithArgs(3, 0, 0).
+			WillReturnRows(scorecards)
+		mock.ExpectQuery("SELECT .+ FROM scorecard_items si").
+			WithArgs(3, 0, 0).
+			WillReturnRows(items)
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/export?format=pdf", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"UNSUPPORTED_FORMAT"}}`, string(body))
+	})
+
+	t.Run("wide", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectQueries(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/export", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		assert.Equal(`attachment; filename="scorecards.csv"`, resp.Header.Get("Content-Disposition"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(strings.Join([]string{
+			"User,Score,Syllabus 1,Syllabus 1 / Assignment 1,Syllabus 2,Outdated,Published At",
+			"User 1,80,90,90,70,false,2024-01-01 00:00:00",
+			"User 2,40,80,80,0,true,2024-01-01 00:00:00",
+			"",
+		}, "\n"), string(body))
+	})
+
+	t.Run("long", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectQueries(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/export?layout=long", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(strings.Join([]string{
+			"User,Structure,Score,Outdated,Published At",
+			"User 1,,80,false,2024-01-01 00:00:00",
+			"User 1,Syllabus 1,90,false,2024-01-01 00:00:00",
+			"User 1,Syllabus 1 / Assignment 1,90,false,2024-01-01 00:00:00",
+			"User 1,Syllabus 2,70,false,2024-01-01 00:00:00",
+			"User 2,,40,true,2024-01-01 00:00:00",
+			"User 2,Syllabus 1,80,true,2024-01-01 00:00:00",
+			"User 2,Syllabus 1 / Assignment 1,80,true,2024-01-01 00:00:00",
+			"User 2,Syllabus 2,0,true,2024-01-01 00:00:00",
+			"",
+		}, "\n"), string(body))
+	})
+}
 
-	mock.MatchExpectationsInOrder(false)
+func Test_scorecard(t *testing.T) {
+	assert := assert.New(t)
 
 	scorecardID := 2
-	mock.ExpectQuery("SELECT .+ FROM scorecards").
-		WithArgs("1", scorecardID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "score", "is_outdated", "generated_at"}).AddRow(scorecardID, 1, 100, false, "2024-01-01 00:00:00"))
 
-	mock.ExpectQuery("SELECT .+ FROM users WHERE id IN (?)").
-		WithArgs(1).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1"))
+	t.Run("published", func(t *testing.T) {
+		db, mock := db.New()
+		generator := scorecard.NewGenerator()
+		h := New(db, generator)
 
-	mock.ExpectQuery("SELECT .+ FROM scorecard_items").
-		WithArgs(scorecardID).
-		WillReturnRows(sqlmock.NewRows([]string{"structure_id", "score"}).AddRow(1, 100))
+		mock.MatchExpectationsInOrder(false)
 
-	app := fiber.New()
-	h.Register(app, middleware.New())
+		mock.ExpectQuery("SELECT id, user_id, published_score AS score, .+ FROM scorecards .+ AND published_score IS NOT NULL").
+			WithArgs("1", scorecardID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "score", "published_score", "is_outdated", "is_published", "generated_at", "published_at"}).
+					AddRow(scorecardID, 1, 90, 90, false, false, "2024-01-02 00:00:00", "2024-01-01 00:00:00"),
+			)
 
-	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/2", nil)
+		mock.ExpectQuery("SELECT .+ FROM users WHERE id IN (?)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1"))
 
-	resp, _ := app.Test(req)
-	assert.Nil(t, mock.ExpectationsWereMet())
-	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
-	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"scorecard":{"id":2,"user":{"id":1,"name":"User 1"},"score":100,"items":[{"structureId":1,"score":100}],"isOutdated":false,"isInQueue":false,"generatedAt":"2024-01-01T00:00:00Z"},"error":null}`, string(body))
+		mock.ExpectQuery("SELECT structure_id, published_score AS score, published_score, published_feedback AS feedback FROM scorecard_items WHERE scorecard_id = \\? AND published_score IS NOT NULL").
+			WithArgs(scorecardID).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "score", "published_score", "feedback"}).AddRow(1, 90, 90, "Good job"))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"scorecard":{"id":2,"user":{"id":1,"name":"User 1"},"score":90,"publishedScore":90,"items":[{"structureId":1,"score":90,"publishedScore":90,"feedback":"Good job"}],"isOutdated":false,"isPublished":false,"isInQueue":false,"generatedAt":"2024-01-02T00:00:00Z","publishedAt":"2024-01-01T00:00:00Z"},"error":null}`, string(body))
+	})
+
+	t.Run("not published", func(t *testing.T) {
+		db, mock := db.New()
+		generator := scorecard.NewGenerator()
+		h := New(db, generator)
+
+		mock.ExpectQuery("SELECT .+ FROM scorecards .+ AND published_score IS NOT NULL").
+			WithArgs("1", scorecardID).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("draft", func(t *testing.T) {
+		db, mock := db.New()
+		generator := scorecard.NewGenerator()
+		h := New(db, generator)
+
+		mock.MatchExpectationsInOrder(false)
+
+		mock.ExpectQuery("SELECT id, user_id, score AS score, .+ FROM scorecards .+ AND score IS NOT NULL").
+			WithArgs("1", scorecardID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "user_id", "score", "published_score", "is_outdated", "is_published", "generated_at", "published_at"}).
+					AddRow(scorecardID, 1, 100, nil, false, false, "2024-01-02 00:00:00", nil),
+			)
+
+		mock.ExpectQuery("SELECT .+ FROM users WHERE id IN (?)").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "User 1"))
+
+		mock.ExpectQuery("SELECT si.structure_id, si.score, si.published_score, us.feedback FROM scorecard_items .+ LEFT JOIN user_scores .+ WHERE si.scorecard_id = \\?$").
+			WithArgs(1, scorecardID).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "score", "published_score", "feedback"}).AddRow(1, 100, nil, nil))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/2/draft", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"scorecard":{"id":2,"user":{"id":1,"name":"User 1"},"score":100,"publishedScore":null,"items":[{"structureId":1,"score":100,"publishedScore":null,"feedback":null}],"isOutdated":false,"isPublished":false,"isInQueue":false,"generatedAt":"2024-01-02T00:00:00Z","publishedAt":null},"error":null}`, string(body))
+	})
+}
+
+func Test_publishScorecards(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("program", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE scorecard_items SET published_score = score, published_feedback = \\( SELECT us.feedback").
+			WithArgs(1, 0, 0, 3).
+			WillReturnResult(sqlmock.NewResult(0, 4))
+		mock.ExpectExec("UPDATE scorecards SET published_score = score").
+			WithArgs(1, 0, 0, 3).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/publish", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"count":2,"error":null}`, string(body))
+	})
+
+	t.Run("scorecard", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectExec("UPDATE scorecard_items SET published_score = score, published_feedback = \\( SELECT us.feedback").
+			WithArgs(1, 2, 2, 0).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("UPDATE scorecards SET published_score = score").
+			WithArgs(1, 2, 2, 0).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/publish/2", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"count":1,"error":null}`, string(body))
+	})
 }
@@ -0,0 +1,351 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/structure"
+	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
+	"github.com/rs/zerolog/log"
+)
+
+// syllabusRefs resolves the references to the syllabuses of a program in both directions
+type syllabusRefs struct {
+	refs  map[int]*structure.SyllabusRef // syllabusID -> ref
+	codes map[string]int                 // code -> syllabusID
+	paths map[string]int                 // titles joined with \x00 -> syllabusID
+}
+
+// Ref returns the code of the syllabus, or the path to it if it doesn't have one
+func (r *syllabusRefs) Ref(syllabusID *int) *structure.SyllabusRef {
+	if syllabusID == nil {
+		return nil
+	}
+	return r.refs[*syllabusID]
+}
+
+// ID returns the syllabus the reference points to. ok is false if it doesn't point to a syllabus of the program.
+func (r *syllabusRefs) ID(ref *structure.SyllabusRef) (*int, bool) {
+	if ref == nil {
+		return nil, true
+	}
+	id, ok := r.codes[ref.Code]
+	if ref.Code == "" {
+		id, ok = r.paths[strings.Join(ref.Path, "\x00")]
+	}
+	if !ok {
+		return nil, false
+	}
+	return &id, true
+}
+
+func (h *Handler) getSyllabusRefs(ctx context.Context, programID int) (*syllabusRefs, error) {
+	var syllabuses []*struct {
+		ID       int
+		ParentID *int `db:"parent_id"`
+		Title    string
+		Code     *string
+	}
+	err := h.db.SelectContext(ctx, &syllabuses, `
+		SELECT s.id, s.parent_id, s.title, s.code
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("structure.getSyllabusRefs")
+		return nil, constant.ErrInternalServerError
+	}
+
+	parents := make(map[int]*int, len(syllabuses))
+	titles := make(map[int]string, len(syllabuses))
+	for _, syllabus := range syllabuses {
+		parents[syllabus.ID] = syllabus.ParentID
+		titles[syllabus.ID] = syllabus.Title
+	}
+
+	r := syllabusRefs{
+		refs:  make(map[int]*structure.SyllabusRef, len(syllabuses)),
+		codes: make(map[string]int),
+		paths: make(map[string]int, len(syllabuses)),
+	}
+	for _, syllabus := range syllabuses {
+		// Titles are unique on every level, so the path to a syllabus is unique too
+		path := []string{syllabus.Title}
+		for parentID := syllabus.ParentID; parentID != nil; parentID = parents[*parentID] {
+			path = append([]string{titles[*parentID]}, path...)
+		}
+		r.paths[strings.Join(path, "\x00")] = syllabus.ID
+
+		if syllabus.Code != nil && *syllabus.Code != "" {
+			r.codes[*syllabus.Code] = syllabus.ID
+			r.refs[syllabus.ID] = &structure.SyllabusRef{Code: *syllabus.Code}
+		} else {
+			r.refs[syllabus.ID] = &structure.SyllabusRef{Path: path}
+		}
+	}
+
+	return &r, nil
+}
+
+// getStructureNodes returns the scorecard structures of the definition as a tree
+func (h *Handler) getStructureNodes(ctx context.Context, definitionID int, refs *syllabusRefs) ([]*structure.Node, error) {
+	var structures []*struct {
+		ID         int
+		ParentID   *int `db:"parent_id"`
+		Title      string
+		SyllabusID *int `db:"syllabus_id"`
+		Weight     float64
+		IsLinked   bool `db:"is_linked"`
+	}
+	err := h.db.SelectContext(ctx, &structures, `
+		SELECT id, parent_id, title, syllabus_id, weight, is_linked
+		FROM scorecard_structures
+		WHERE definition_id = ?
+		ORDER BY position, rowid
+	`, definitionID)
+	if err != nil {
+		log.Error().Err(err).Msg("structure.getStructureNodes")
+		return nil, constant.ErrInternalServerError
+	}
+
+	m := make(map[int]*structure.Node, len(structures))
+	for _, v := range structures {
+		m[v.ID] = &structure.Node{ID: v.ID, Title: v.Title, Syllabus: refs.Ref(v.SyllabusID), IsLinked: v.IsLinked}
+		if v.Weight != 1 {
+			m[v.ID].Weight = &v.Weight
+		}
+	}
+
+	nodes := []*structure.Node{}
+	for _, v := range structures {
+		if v.ParentID == nil {
+			nodes = append(nodes, m[v.ID])
+		} else if parent, ok := m[*v.ParentID]; ok {
+			parent.Children = append(parent.Children, m[v.ID])
+		}
+	}
+	return nodes, nil
+}
+
+// ?format json|yaml
+
+// exportScorecardStructures writes the scorecard structures of the definition into a file that can be imported into
+// any program with the same syllabuses
+func (h *Handler) exportScorecardStructures(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	format := structure.Format(c.Query("format", string(structure.FormatJSON)))
+	if format != structure.FormatJSON && format != structure.FormatYAML {
+		result.Error = fiber.Map{"code": "UNSUPPORTED_FORMAT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	refs, err := h.getSyllabusRefs(c.UserContext(), programID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	nodes, err := h.getStructureNodes(c.UserContext(), definitionID, refs)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var buf bytes.Buffer
+	if err := structure.Write(&buf, format, &structure.Document{Structures: nodes}); err != nil {
+		log.Error().Err(err).Msg("structure.exportScorecardStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	c.Attachment("structures." + string(format))
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// ?dryRun bool
+
+// importScorecardStructures makes the scorecard structures of the definition match the uploaded file. The structures
+// are matched by their titles, so the ones that are kept keep their scores. With dryRun, only the diff is returned.
+func (h *Handler) importScorecardStructures(c *fiber.Ctx) error {
+	var result struct {
+		Success bool            `json:"success"`
+		Diff    *structure.Diff `json:"diff"`
+		Error   any             `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		result.Error = fiber.Map{"code": "FILE_IS_REQUIRED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	format, err := structure.FormatFromFilename(file.Filename)
+	if err != nil {
+		result.Error = fiber.Map{"code": "UNSUPPORTED_FORMAT"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		log.Error().Err(err).Msg("structure.importScorecardStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer f.Close()
+
+	doc, err := structure.Read(f, format)
+	if err != nil {
+		result.Error = fiber.Map{"code": "INVALID_FILE", "message": err.Error()}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	refs, err := h.getSyllabusRefs(c.UserContext(), programID)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// A syllabus can be referenced by its path even if it has a code, the references are written the same way as in an
+	// export so they can be compared
+	syllabusIds := make(map[*structure.Node]*int)
+	var resolve func(nodes []*structure.Node) *structure.SyllabusRef
+	resolve = func(nodes []*structure.Node) *structure.SyllabusRef {
+		for _, node := range nodes {
+			syllabusID, ok := refs.ID(node.Syllabus)
+			if !ok {
+				return node.Syllabus
+			}
+			syllabusIds[node] = syllabusID
+			node.Syllabus = refs.Ref(syllabusID)
+			if ref := resolve(node.Children); ref != nil {
+				return ref
+			}
+		}
+		return nil
+	}
+	if ref := resolve(doc.Structures); ref != nil {
+		result.Error = fiber.Map{"code": "SYLLABUS_NOT_FOUND", "message": ref.String()}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	nodes, err := h.getStructureNodes(c.UserContext(), definitionID, refs)
+	if err != nil {
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Diff = structure.Compare(nodes, doc.Structures)
+	if c.QueryBool("dryRun") || result.Diff.IsEmpty() {
+		result.Success = true
+		return c.Status(fiber.StatusOK).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	if err := applyStructureDiff(c.UserContext(), tx, programID, definitionID, doc.Structures, result.Diff, syllabusIds); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("structure.importScorecardStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// applyStructureDiff deletes the removed structures, updates the changed ones and inserts the added ones in the order
+// of nodes. The linked structures are synced afterwards, as a linked structure always mirrors its syllabus.
+func applyStructureDiff(ctx context.Context, tx *sqlx.Tx, programID, definitionID int, nodes []*structure.Node, diff *structure.Diff, syllabusIds map[*structure.Node]*int) error {
+	// The structures under a removed one are removed along with it
+	var deleteIds []int
+	for _, change := range diff.Removed {
+		deleteIds = append(deleteIds, change.Before.ID)
+	}
+	if len(deleteIds) > 0 {
+		query, args, err := sqlx.In(`DELETE FROM scorecard_structures WHERE id IN (?)`, deleteIds)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+
+	isChanged := make(map[int]bool, len(diff.Changed))
+	for _, change := range diff.Changed {
+		isChanged[change.Before.ID] = true
+	}
+
+	var save func(nodes []*structure.Node, parentID *int) error
+	save = func(nodes []*structure.Node, parentID *int) error {
+		for i, node := range nodes {
+			if node.ID == 0 {
+				err := tx.QueryRowContext(ctx, `
+					INSERT INTO scorecard_structures (program_id, definition_id, parent_id, title, syllabus_id, position, weight, is_linked)
+					VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+					RETURNING id
+				`, programID, definitionID, parentID, node.Title, syllabusIds[node], i, node.GetWeight(), node.IsLinked).Scan(&node.ID)
+				if err != nil {
+					return err
+				}
+			} else if isChanged[node.ID] {
+				_, err := tx.ExecContext(ctx, `
+					UPDATE scorecard_structures
+					SET syllabus_id = ?, position = ?, weight = ?, is_linked = ?
+					WHERE id = ?
+				`, syllabusIds[node], i, node.GetWeight(), node.IsLinked, node.ID)
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := save(node.Children, &node.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := save(nodes, nil); err != nil {
+		return err
+	}
+
+	if err := syncLinkedStructures(ctx, tx, programID); err != nil {
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE scorecards SET is_outdated = TRUE WHERE definition_id = ?`, definitionID)
+	return err
+}
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func expectStructureNodes(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+		WithArgs(1).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "title", "code"}).
+				AddRow(1, nil, "Syllabus 1", nil).
+				AddRow(2, 1, "Assignment 1", "A1").
+				AddRow(3, 1, "Assignment 2", nil),
+		)
+	mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+		WithArgs(3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "title", "syllabus_id", "weight", "is_linked"}).
+				AddRow(10, nil, "Total", nil, 1, false).
+				AddRow(11, 10, "Assignment 1", 2, 2, false).
+				AddRow(12, 10, "Old", nil, 1, false),
+		)
+}
+
+func Test_exportScorecardStructures(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("unsupported format", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/structures/export?format=xml", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"UNSUPPORTED_FORMAT"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+		expectStructureNodes(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/structures/export?format=yaml", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		assert.Equal(`attachment; filename="structures.yaml"`, resp.Header.Get("Content-Disposition"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(strings.Join([]string{
+			"structures:",
+			"  - title: Total",
+			"    children:",
+			"      - title: Assignment 1",
+			"        syllabus:",
+			"          code: A1",
+			"        weight: 2",
+			"      - title: Old",
+			"",
+		}, "\n"), string(body))
+	})
+}
+
+func newImportStructuresRequest(query, filename, data string) *http.Request {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	part, _ := w.CreateFormFile("file", filename)
+	part.Write([]byte(data))
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/v1/programs/1/scorecards/structures/import"+query, &b)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func Test_importScorecardStructures(t *testing.T) {
+	assert := assert.New(t)
+
+	data := `{"structures":[{"title":"Total","children":[{"title":"Assignment 2","syllabus":{"path":["Syllabus 1","Assignment 2"]}},{"title":"Assignment 1","syllabus":{"path":["Syllabus 1","Assignment 1"]}}]}]}`
+	diff := `{"added":[{"path":["Total","Assignment 2"],"before":null,"after":{"title":"Assignment 2","syllabus":{"path":["Syllabus 1","Assignment 2"]}}}],"removed":[{"path":["Total","Old"],"before":{"title":"Old"},"after":null}],"changed":[{"path":["Total","Assignment 1"],"before":{"title":"Assignment 1","syllabus":{"code":"A1"},"weight":2},"after":{"title":"Assignment 1","syllabus":{"code":"A1"}},"fields":["weight","position"]}]}`
+
+	t.Run("unsupported format", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportStructuresRequest("", "structures.xml", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"diff":null,"error":{"code":"UNSUPPORTED_FORMAT"}}`, string(body))
+	})
+
+	t.Run("syllabus not found", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "title", "code"}).AddRow(1, nil, "Syllabus 1", nil))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportStructuresRequest("", "structures.yaml", "structures:\n  - title: Final\n    syllabus:\n      code: F\n"))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"diff":null,"error":{"code":"SYLLABUS_NOT_FOUND","message":"F"}}`, string(body))
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+		expectStructureNodes(mock)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportStructuresRequest("?dryRun=true", "structures.json", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"diff":`+diff+`,"error":null}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectScorecardDefinitionID(mock, 3)
+		expectStructureNodes(mock)
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM scorecard_structures WHERE id IN \\(\\?\\)").
+			WithArgs(12).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").
+			WithArgs(1, 3, 10, "Assignment 2", 3, 0, 1.0, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(13))
+		mock.ExpectExec("UPDATE scorecard_structures SET syllabus_id = \\?, position = \\?, weight = \\?, is_linked = \\?").
+			WithArgs(2, 1, 1.0, false, 11).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		resp, _ := app.Test(newImportStructuresRequest("", "structures.json", data))
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"diff":`+diff+`,"error":null}`, string(body))
+	})
+}
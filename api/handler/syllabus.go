@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"slices"
 	"strconv"
+	"strings"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/brantem/scorecard/constant"
 	"github.com/brantem/scorecard/model"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jmoiron/sqlx"
 	"github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 )
@@ -80,7 +85,7 @@ func (h *Handler) saveSyllabusStructure(c *fiber.Ctx) error {
 	}
 
 	if structureID, _ := c.ParamsInt("structureId"); structureID != 0 {
-		// TODO: Update prev_id
+		// prev_id is updated by moveSyllabusStructure
 		_, err := h.db.ExecContext(c.UserContext(), `
 			UPDATE syllabus_structures
 			SET title = ?
@@ -134,6 +139,196 @@ func (h *Handler) saveSyllabusStructure(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
+// sortSyllabusStructures returns the ids of the structures in the order of the prev_id chain, without the Assignment
+// row. ok is false if the chain isn't linear, i.e. it branches, loops or has structures that can't be reached.
+func sortSyllabusStructures(structures map[int]*int) ([]int, bool) {
+	var head, n int
+	next := make(map[int]int, len(structures))
+	for id, prevID := range structures {
+		switch {
+		case prevID == nil:
+			if head != 0 {
+				return nil, false
+			}
+			head = id
+		case *prevID == -1:
+			continue
+		default:
+			if _, ok := next[*prevID]; ok {
+				return nil, false
+			}
+			next[*prevID] = id
+		}
+		n++
+	}
+
+	var ids []int
+	for id := head; id != 0 && len(ids) < n; id = next[id] {
+		ids = append(ids, id)
+	}
+	return ids, len(ids) == n
+}
+
+// syllabusStructureLevels returns the level of every structure, starting from 0, where ids is the chain returned by
+// sortSyllabusStructures. The Assignment row is always the last level.
+func syllabusStructureLevels(structures map[int]*int, ids []int) map[int]int {
+	levels := make(map[int]int, len(structures))
+	for i, id := range ids {
+		levels[id] = i
+	}
+	for id, prevID := range structures {
+		if prevID != nil && *prevID == -1 {
+			levels[id] = len(ids)
+		}
+	}
+	return levels
+}
+
+func getSyllabusStructures(ctx context.Context, tx *sqlx.Tx, programID int) (map[int]*int, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, prev_id
+		FROM syllabus_structures
+		WHERE program_id = ?
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	structures := make(map[int]*int)
+	for rows.Next() {
+		var id int
+		var prevID *int
+		if err := rows.Scan(&id, &prevID); err != nil {
+			return nil, err
+		}
+		structures[id] = prevID
+	}
+	return structures, nil
+}
+
+func (h *Handler) moveSyllabusStructure(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	structureID, _ := c.ParamsInt("structureId")
+
+	var body struct {
+		PrevID *int `json:"prevId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("syllabus.moveSyllabusStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	structures, err := getSyllabusStructures(c.UserContext(), tx, programID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabusStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if v := structures[structureID]; v != nil && *v == -1 {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "ASSIGNMENT_CANNOT_BE_MOVED"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	ids, ok := sortSyllabusStructures(structures)
+	if !ok {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "STRUCTURES_ARE_NOT_LINEAR"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+
+	ids = slices.DeleteFunc(ids, func(id int) bool { return id == structureID })
+	i := 0
+	if body.PrevID != nil {
+		i = slices.Index(ids, *body.PrevID) + 1
+		if i == 0 {
+			tx.Rollback()
+			result.Error = fiber.Map{"code": "INVALID_PREV_ID"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+	}
+	ids = slices.Insert(ids, i, structureID)
+
+	levels := syllabusStructureLevels(structures, ids)
+
+	// Every syllabus has to stay one level below its parent
+	rows, err := tx.QueryContext(c.UserContext(), `
+		SELECT DISTINCT s.structure_id, COALESCE(p.structure_id, 0) AS parent_structure_id
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		LEFT JOIN syllabuses p ON p.id = s.parent_id
+		WHERE ss.program_id = ?
+	`, programID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabusStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var structureID, parentStructureID int
+		if err := rows.Scan(&structureID, &parentStructureID); err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("syllabus.moveSyllabusStructure")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		expected := -1
+		if parentStructureID != 0 {
+			expected = levels[parentStructureID]
+		}
+		if levels[structureID] != expected+1 {
+			tx.Rollback()
+			result.Error = fiber.Map{"code": "SYLLABUS_HIERARCHY_WOULD_BREAK"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+	}
+
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		if i == 0 {
+			values[i] = fmt.Sprintf("(%d, NULL)", id)
+		} else {
+			values[i] = fmt.Sprintf("(%d, %d)", id, ids[i-1])
+		}
+	}
+
+	_, err = tx.ExecContext(c.UserContext(), fmt.Sprintf(`
+		WITH t(id, prev_id) AS (
+		  VALUES %s
+		)
+		UPDATE syllabus_structures
+		SET prev_id = t.prev_id
+		FROM t
+		WHERE syllabus_structures.id = t.id
+	`, strings.Join(values, ", ")))
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabusStructure")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
 func (h *Handler) deleteSyllabusStructure(c *fiber.Ctx) error {
 	var result struct {
 		Success bool `json:"success"`
@@ -189,11 +384,13 @@ func (h *Handler) syllabuses(c *fiber.Ctx) error {
 	result.Nodes = []*model.Syllabus{}
 
 	rows, err := h.db.QueryxContext(c.UserContext(), `
-		SELECT s.id, s.parent_id, s.structure_id, s.title
+		SELECT
+		  s.id, s.parent_id, s.structure_id, s.title, s.description, s.code, s.position, s.tags, s.max_score, s.due_at,
+		  s.visibility, s.is_locked
 		FROM syllabuses s
 		JOIN syllabus_structures ss ON ss.id = s.structure_id
 		WHERE ss.program_id = ?
-		ORDER BY s.rowid
+		ORDER BY s.position, s.rowid
 	`, c.Params("programId"))
 	if err != nil {
 		log.Error().Err(err).Msg("syllabus.syllabuses")
@@ -216,9 +413,226 @@ func (h *Handler) syllabuses(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(result)
 }
 
+// ?rootId int
+// ?depth int
+// ?stats bool
+
+func (h *Handler) syllabusTree(c *fiber.Ctx) error {
+	type Level struct {
+		Level       int    `json:"level"`
+		StructureID int    `json:"structureId"`
+		Title       string `json:"title"`
+		Count       int    `json:"count"`
+	}
+
+	type Stats struct {
+		ScoreCount    int     `json:"scoreCount"`
+		ExpectedCount int     `json:"expectedCount"`
+		Completion    float64 `json:"completion"`
+	}
+
+	type Node struct {
+		model.Syllabus
+		Level      int     `json:"level" db:"-"`
+		ChildCount int     `json:"childCount" db:"child_count"`
+		Stats      *Stats  `json:"stats,omitempty" db:"-"`
+		Children   []*Node `json:"children" db:"-"`
+	}
+
+	var result struct {
+		Levels []*Level `json:"levels"`
+		Nodes  []*Node  `json:"nodes"`
+		Error  any      `json:"error"`
+	}
+	result.Levels = []*Level{}
+	result.Nodes = []*Node{}
+
+	programID, _ := c.ParamsInt("programId")
+	rootID := c.QueryInt("rootId")
+	depth := c.QueryInt("depth")
+
+	rows, err := h.db.QueryContext(c.UserContext(), `
+		SELECT id, prev_id, title
+		FROM syllabus_structures
+		WHERE program_id = ?
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("syllabus.syllabusTree")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	structures := make(map[int]*int)
+	titles := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var prevID *int
+		var title string
+		if err := rows.Scan(&id, &prevID, &title); err != nil {
+			log.Error().Err(err).Msg("syllabus.syllabusTree")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		structures[id] = prevID
+		titles[id] = title
+	}
+
+	ids, ok := sortSyllabusStructures(structures)
+	if !ok {
+		result.Error = fiber.Map{"code": "STRUCTURES_ARE_NOT_LINEAR"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+	levels := syllabusStructureLevels(structures, ids)
+
+	result.Levels = make([]*Level, len(levels))
+	for id, level := range levels {
+		result.Levels[level] = &Level{Level: level, StructureID: id, Title: titles[id]}
+	}
+
+	// If rootID == 0, the tree starts from the root syllabuses
+	rows, err = h.db.QueryContext(c.UserContext(), `
+		WITH RECURSIVE t AS (
+		  SELECT s.*, s.rowid, 1 AS depth
+		  FROM syllabuses s
+		  JOIN syllabus_structures ss ON ss.id = s.structure_id
+		  WHERE ss.program_id = ?
+		    AND (CASE WHEN ? = 0 THEN s.parent_id IS NULL ELSE s.id = ? END)
+		  UNION ALL
+		  SELECT s.*, s.rowid, t.depth + 1
+		  FROM syllabuses s
+		  JOIN t ON s.parent_id = t.id
+		  WHERE (? = 0 OR t.depth < ?)
+		)
+		SELECT
+		  t.id, t.parent_id, t.structure_id, t.title, t.description, t.code, t.position, t.tags, t.max_score, t.due_at,
+		  t.visibility, t.is_locked, (SELECT COUNT(id) FROM syllabuses WHERE parent_id = t.id) AS child_count
+		FROM t
+		ORDER BY t.position, t.rowid
+	`, programID, rootID, rootID, depth, depth)
+	if err != nil {
+		log.Error().Err(err).Msg("syllabus.syllabusTree")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	var nodes []*Node
+	m := make(map[int]*Node)
+	for rows.Next() {
+		var node Node
+		err := rows.Scan(
+			&node.ID, &node.ParentID, &node.StructureID, &node.Title, &node.Description, &node.Code, &node.Position,
+			&node.Tags, &node.MaxScore, &node.DueAt, &node.Visibility, &node.IsLocked, &node.ChildCount,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("syllabus.syllabusTree")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		node.Level = levels[*node.StructureID]
+		node.Children = []*Node{}
+		nodes = append(nodes, &node)
+		m[node.ID] = &node
+	}
+
+	if rootID != 0 && len(nodes) == 0 {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	for _, node := range nodes {
+		if node.ParentID != nil && node.ID != rootID {
+			if parent, ok := m[*node.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		result.Nodes = append(result.Nodes, node)
+	}
+	for _, node := range nodes {
+		result.Levels[node.Level].Count++
+	}
+	c.Set("X-Total-Count", strconv.Itoa(len(nodes)))
+
+	if c.QueryBool("stats") && len(nodes) > 0 {
+		stats, err := h.getSyllabusCompletion(c.UserContext(), programID)
+		if err != nil {
+			log.Error().Err(err).Msg("syllabus.syllabusTree")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		for _, node := range nodes {
+			if v, ok := stats[node.ID]; ok {
+				node.Stats = &Stats{ScoreCount: v[0], ExpectedCount: v[1]}
+				if v[1] > 0 {
+					node.Stats.Completion = float64(v[0]) / float64(v[1])
+				}
+			}
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// getSyllabusCompletion returns the number of scores that were entered and the number of scores that are expected for
+// every syllabus of the program. A syllabus that isn't an assignment counts the scores of the assignments below it.
+func (h *Handler) getSyllabusCompletion(ctx context.Context, programID int) (map[int][2]int, error) {
+	var userCount int
+	if err := h.db.QueryRowContext(ctx, `SELECT COUNT(id) FROM users WHERE program_id = ?`, programID).Scan(&userCount); err != nil {
+		return nil, err
+	}
+
+	rows, err := h.db.QueryContext(ctx, `
+		SELECT s.id, s.parent_id, COALESCE(ss.prev_id, 0) = -1 AS is_assignment, COUNT(us.user_id) AS score_count
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		LEFT JOIN user_scores us ON us.syllabus_id = s.id
+		WHERE ss.program_id = ?
+		GROUP BY s.id
+	`, programID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int][2]int)
+	parents := make(map[int]*int)
+	var assignmentIds []int
+	for rows.Next() {
+		var id, scoreCount int
+		var parentID *int
+		var isAssignment bool
+		if err := rows.Scan(&id, &parentID, &isAssignment, &scoreCount); err != nil {
+			return nil, err
+		}
+		parents[id] = parentID
+		stats[id] = [2]int{}
+		if isAssignment {
+			stats[id] = [2]int{scoreCount, userCount}
+			assignmentIds = append(assignmentIds, id)
+		}
+	}
+
+	for _, id := range assignmentIds {
+		v := stats[id]
+		for parentID := parents[id]; parentID != nil; parentID = parents[*parentID] {
+			if _, ok := stats[*parentID]; !ok {
+				break
+			}
+			p := stats[*parentID]
+			stats[*parentID] = [2]int{p[0] + v[0], p[1] + v[1]}
+		}
+	}
+
+	return stats, nil
+}
+
 func (h *Handler) syllabus(c *fiber.Ctx) error {
 	type Syllabus struct {
 		model.BaseSyllabus
+		model.SyllabusMetadata
+		IsLocked     bool                  `json:"isLocked" db:"is_locked"`
 		Parents      []*model.BaseSyllabus `json:"parents"`
 		IsAssignment bool                  `json:"isAssignment" db:"is_assignment"`
 	}
@@ -242,7 +656,9 @@ func (h *Handler) syllabus(c *fiber.Ctx) error {
 		  FROM syllabuses s
 		  INNER JOIN t ON s.id = t.parent_id
 		)
-		SELECT t.id, t.title, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
+		SELECT
+		  t.id, t.title, t.description, t.code, t.position, t.tags, t.max_score, t.due_at, t.visibility, t.is_locked,
+		  COALESCE(ss.prev_id, 0) = -1 AS is_assignment
 		FROM t
 		JOIN syllabus_structures ss ON ss.id = t.structure_id
 	`, syllabusID, c.Params("programId"))
@@ -281,10 +697,20 @@ func (h *Handler) saveSyllabus(c *fiber.Ctx) error {
 		Error   any  `json:"error"`
 	}
 
+	programID, _ := c.ParamsInt("programId")
+	syllabusID, _ := c.ParamsInt("syllabusId")
+
+	// The position isn't in the body, the order is only changed by moveSyllabus
 	var body struct {
-		ParentID    *int   `json:"parentId"`
-		StructureID int    `json:"structureId"`
-		Title       string `json:"title"`
+		ParentID    *int        `json:"parentId"`
+		StructureID int         `json:"structureId"`
+		Title       string      `json:"title"`
+		Description string      `json:"description"`
+		Code        *string     `json:"code"`
+		Tags        model.Tags  `json:"tags"`
+		MaxScore    *float64    `json:"maxScore"`
+		DueAt       *model.Time `json:"dueAt"`
+		Visibility  string      `json:"visibility"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		log.Error().Err(err).Msg("syllabus.saveSyllabus")
@@ -292,13 +718,65 @@ func (h *Handler) saveSyllabus(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
 
-	if syllabusID, _ := c.ParamsInt("syllabusID"); syllabusID != 0 {
-		_, err := h.db.ExecContext(c.UserContext(), `
+	switch body.Visibility {
+	case "":
+		body.Visibility = model.SyllabusVisibilityVisible
+	case model.SyllabusVisibilityVisible, model.SyllabusVisibilityHidden:
+	default:
+		result.Error = fiber.Map{"code": "INVALID_VISIBILITY"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	if body.MaxScore != nil && *body.MaxScore <= 0 {
+		result.Error = fiber.Map{"code": "INVALID_MAX_SCORE"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	if body.Code != nil {
+		if code := strings.TrimSpace(*body.Code); code != "" {
+			body.Code = &code
+		} else {
+			body.Code = nil
+		}
+	}
+
+	// Codes are unique per program, which can't be enforced by the schema as syllabuses don't have a program_id
+	if body.Code != nil {
+		var isExists bool
+		err := h.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
+		  SELECT s.id
+		  FROM syllabuses s
+		  JOIN syllabus_structures ss ON ss.id = s.structure_id
+		  WHERE ss.program_id = ?
+		    AND s.code = ?
+		    AND s.id != ?
+		)`, programID, *body.Code, syllabusID).Scan(&isExists)
+		if err != nil {
+			log.Error().Err(err).Msg("syllabus.saveSyllabus")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		if isExists {
+			result.Error = fiber.Map{"code": "CODE_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+	}
+
+	if body.Tags == nil {
+		body.Tags = model.Tags{}
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	if syllabusID != 0 {
+		// parent_id and position are updated by moveSyllabus
+		_, err := tx.ExecContext(c.UserContext(), `
 			UPDATE syllabuses
-			SET parent_id = ?, title = ?
+			SET title = ?, description = ?, code = ?, tags = ?, max_score = ?, due_at = ?, visibility = ?
 			WHERE id = ?
-		`, body.ParentID, body.Title, syllabusID)
+		`, body.Title, body.Description, body.Code, body.Tags, body.MaxScore, body.DueAt, body.Visibility, syllabusID)
 		if err != nil {
+			tx.Rollback()
 			if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
 				result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
 				return c.Status(fiber.StatusConflict).JSON(result)
@@ -308,11 +786,19 @@ func (h *Handler) saveSyllabus(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusInternalServerError).JSON(result)
 		}
 	} else {
-		_, err := h.db.ExecContext(c.UserContext(), `
-			INSERT INTO syllabuses (parent_id, structure_id, title)
-			VALUES (?, ?, ?)
-		`, body.ParentID, body.StructureID, body.Title)
+		// New syllabuses are added after their siblings
+		_, err := tx.ExecContext(c.UserContext(), `
+			INSERT INTO syllabuses (parent_id, structure_id, title, description, code, position, tags, max_score, due_at, visibility)
+			VALUES (?, ?, ?, ?, ?, (
+			  SELECT COALESCE(MAX(s.position) + 1, 0)
+			  FROM syllabuses s
+			  JOIN syllabus_structures ss ON ss.id = s.structure_id
+			  WHERE ss.program_id = ?
+			    AND s.parent_id IS ?
+			), ?, ?, ?, ?)
+		`, body.ParentID, body.StructureID, body.Title, body.Description, body.Code, programID, body.ParentID, body.Tags, body.MaxScore, body.DueAt, body.Visibility)
 		if err != nil {
+			tx.Rollback()
 			if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
 				result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
 				return c.Status(fiber.StatusConflict).JSON(result)
@@ -323,6 +809,224 @@ func (h *Handler) saveSyllabus(c *fiber.Ctx) error {
 		}
 	}
 
+	if err := syncLinkedStructures(c.UserContext(), tx, programID); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.saveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) moveSyllabus(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	programID, _ := c.ParamsInt("programId")
+	syllabusID, _ := c.ParamsInt("syllabusId")
+
+	var body struct {
+		ParentID *int `json:"parentId"`
+		BeforeID *int `json:"beforeId"`
+		AfterID  *int `json:"afterId"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if body.BeforeID != nil && body.AfterID != nil {
+		result.Error = fiber.Map{"code": "INVALID_SIBLING_ID"}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	structures, err := getSyllabusStructures(c.UserContext(), tx, programID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	ids, ok := sortSyllabusStructures(structures)
+	if !ok {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "STRUCTURES_ARE_NOT_LINEAR"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+	levels := syllabusStructureLevels(structures, ids)
+
+	var structureID int
+	var oldParentID *int
+	err = tx.QueryRowContext(c.UserContext(), `
+		SELECT structure_id, parent_id
+		FROM syllabuses
+		WHERE id = ?
+	`, syllabusID).Scan(&structureID, &oldParentID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// The descendants move along with the syllabus and keep their structures, so only the syllabus itself has to be
+	// checked. This also rules out moving a syllabus into itself or into one of its descendants.
+	expected := 0
+	if body.ParentID != nil {
+		var parentStructureID int
+		err := tx.QueryRowContext(c.UserContext(), `
+			SELECT s.structure_id
+			FROM syllabuses s
+			JOIN syllabus_structures ss ON ss.id = s.structure_id
+			WHERE s.id = ?
+			  AND ss.program_id = ?
+		`, *body.ParentID, programID).Scan(&parentStructureID)
+		if err != nil {
+			tx.Rollback()
+			if err == sql.ErrNoRows {
+				result.Error = fiber.Map{"code": "INVALID_PARENT_ID"}
+				return c.Status(fiber.StatusBadRequest).JSON(result)
+			}
+			log.Error().Err(err).Msg("syllabus.moveSyllabus")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		expected = levels[parentStructureID] + 1
+	}
+	if levels[structureID] != expected {
+		tx.Rollback()
+		result.Error = fiber.Map{"code": "INVALID_PARENT_LEVEL"}
+		return c.Status(fiber.StatusConflict).JSON(result)
+	}
+
+	if _, err := tx.ExecContext(c.UserContext(), `UPDATE syllabuses SET parent_id = ? WHERE id = ?`, body.ParentID, syllabusID); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// The siblings are only renumbered when the syllabus is placed next to one of them, otherwise it keeps its position
+	if body.BeforeID != nil || body.AfterID != nil {
+		var siblings []int
+		err := tx.SelectContext(c.UserContext(), &siblings, `
+			SELECT s.id
+			FROM syllabuses s
+			JOIN syllabus_structures ss ON ss.id = s.structure_id
+			WHERE ss.program_id = ?
+			  AND s.parent_id IS ?
+			  AND s.id != ?
+			ORDER BY s.position, s.rowid
+		`, programID, body.ParentID, syllabusID)
+		if err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("syllabus.moveSyllabus")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+
+		ids, ok := placeSibling(siblings, syllabusID, body.BeforeID, body.AfterID)
+		if !ok {
+			tx.Rollback()
+			result.Error = fiber.Map{"code": "INVALID_SIBLING_ID"}
+			return c.Status(fiber.StatusBadRequest).JSON(result)
+		}
+
+		positions := make([][2]int, len(ids))
+		for i, id := range ids {
+			positions[i] = [2]int{id, i}
+		}
+		if err := relinkRows(c.UserContext(), tx, "syllabuses", "position", positions); err != nil {
+			tx.Rollback()
+			log.Error().Err(err).Msg("syllabus.moveSyllabus")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+
+	// The scorecard structures that mirror the syllabus under its old parent are moved under the one that mirrors the
+	// new parent in the same definition. If the new parent isn't in the structures of a definition, they are left where
+	// they are.
+	_, err = tx.ExecContext(c.UserContext(), `
+		WITH t AS (
+		  SELECT d.id AS definition_id, (
+		    SELECT MIN(ss.id)
+		    FROM scorecard_structures ss
+		    WHERE ss.definition_id = d.id
+		      AND ss.syllabus_id = ?
+		  ) AS parent_id
+		  FROM scorecard_definitions d
+		  WHERE d.program_id = ?
+		)
+		UPDATE scorecard_structures
+		SET parent_id = t.parent_id, position = (
+		  SELECT COALESCE(MAX(ss.position) + 1, 0)
+		  FROM scorecard_structures ss
+		  WHERE ss.definition_id = t.definition_id
+		    AND ss.parent_id IS t.parent_id
+		)
+		FROM t
+		WHERE scorecard_structures.definition_id = t.definition_id
+		  AND (? IS NULL OR t.parent_id IS NOT NULL)
+		  AND scorecard_structures.syllabus_id = ?
+		  AND (
+		    (? IS NULL AND scorecard_structures.parent_id IS NULL)
+		    OR scorecard_structures.parent_id IN (SELECT id FROM scorecard_structures WHERE program_id = ? AND syllabus_id = ?)
+		  )
+	`, body.ParentID, programID, body.ParentID, syllabusID, oldParentID, programID, oldParentID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if err := syncLinkedStructures(c.UserContext(), tx, programID); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	// Only the definitions that reference the moved subtree are affected
+	_, err = tx.ExecContext(c.UserContext(), `
+		WITH RECURSIVE t AS (
+		  SELECT id
+		  FROM syllabuses
+		  WHERE id = ?
+		  UNION
+		  SELECT s.id
+		  FROM syllabuses s
+		  JOIN t ON s.parent_id = t.id
+		)
+		UPDATE scorecards
+		SET is_outdated = TRUE
+		WHERE definition_id IN (
+		  SELECT definition_id
+		  FROM scorecard_structures
+		  WHERE program_id = ?
+		    AND syllabus_id IN (SELECT id FROM t)
+		)
+	`, syllabusID, programID)
+	if err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("syllabus.moveSyllabus")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
 	result.Success = true
 	return c.Status(fiber.StatusOK).JSON(result)
 }
@@ -399,9 +1103,11 @@ func (h *Handler) deleteSyllabus(c *fiber.Ctx) error {
 
 func (h *Handler) syllabusScores(c *fiber.Ctx) error {
 	type Node struct {
-		UserID int         `json:"-" db:"user_id"`
-		User   *model.User `json:"user" db:"-"`
-		Score  *float64    `json:"score"`
+		UserID   int         `json:"-" db:"user_id"`
+		User     *model.User `json:"user" db:"-"`
+		Score    *float64    `json:"score"`
+		Feedback *string     `json:"feedback"`
+		Note     *string     `json:"note"`
 	}
 
 	var result struct {
@@ -437,7 +1143,7 @@ func (h *Handler) syllabusScores(c *fiber.Ctx) error {
 		qb = qb.Offset(uint64(v))
 	}
 
-	query, args, err := qb.Columns("u.id AS user_id", "us.score").OrderBy("u.rowid ASC").ToSql()
+	query, args, err := qb.Columns("u.id AS user_id", "us.score", "us.feedback", "us.note").OrderBy("u.rowid ASC").ToSql()
 	if err != nil {
 		log.Error().Err(err).Msg("syllabus.syllabusScores")
 		result.Error = constant.RespInternalServerError
@@ -479,10 +1185,11 @@ func (h *Handler) saveScore(c *fiber.Ctx) error {
 		Error   any  `json:"error"`
 	}
 
-	userID, _ := c.ParamsInt("userId")
-
 	var body struct {
-		Score float64 `json:"score"`
+		Score    float64 `json:"score"`
+		Feedback *string `json:"feedback"`
+		Note     *string `json:"note"`
+		Reason   string  `json:"reason"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		log.Error().Err(err).Msg("syllabus.saveScore")
@@ -490,23 +1197,33 @@ func (h *Handler) saveScore(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
 
-	tx := h.db.MustBeginTx(c.UserContext(), nil)
+	programID, _ := c.ParamsInt("programId")
+	syllabusID, _ := c.ParamsInt("syllabusId")
+	userID, _ := c.ParamsInt("userId")
 
-	_, err := tx.ExecContext(c.UserContext(), `
-		INSERT INTO user_scores (user_id, syllabus_id, score)
-		VALUES (?, ?, ?)
-		ON CONFLICT (user_id, syllabus_id)
-		DO UPDATE SET score = EXCLUDED.score
-	`, userID, c.Params("syllabusId"), body.Score)
+	// A single score goes through the same checks and the same upsert as the bulk entry
+	rows := []*scoreRow{{userID, syllabusID, body.Score, body.Feedback, body.Note}}
+	rowErrors, err := validateScores(c.UserContext(), h.db, programID, rows)
 	if err != nil {
-		tx.Rollback()
 		log.Error().Err(err).Msg("syllabus.saveScore")
 		result.Error = constant.RespInternalServerError
 		return c.Status(fiber.StatusInternalServerError).JSON(result)
 	}
 
-	_, err = tx.ExecContext(c.UserContext(), `UPDATE scorecards SET is_outdated = TRUE WHERE user_id = ?`, userID)
-	if err != nil {
+	if len(rowErrors) > 0 {
+		result.Error = rowErrors[0].Error
+		switch rowErrors[0].Error.(fiber.Map)["code"] {
+		case "USER_NOT_FOUND", "SYLLABUS_NOT_FOUND":
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		case "SYLLABUS_IS_LOCKED":
+			return c.Status(fiber.StatusLocked).JSON(result)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	if err := saveScores(c.UserContext(), tx, programID, rows, newScoreChange(c, body.Reason)); err != nil {
 		tx.Rollback()
 		log.Error().Err(err).Msg("syllabus.saveScore")
 		result.Error = constant.RespInternalServerError
@@ -1,12 +1,14 @@
 package handler
 
 import (
+	"database/sql"
 	"io"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil"
 	"github.com/brantem/scorecard/testutil/db"
 	"github.com/brantem/scorecard/testutil/middleware"
 	"github.com/gofiber/fiber/v2"
@@ -100,6 +102,44 @@ func Test_saveSyllabusStructure(t *testing.T) {
 		assert.Equal(`{"success":true,"error":null}`, string(body))
 	})
 
+	t.Run("invalid visibility", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2", strings.NewReader(`{"title":"Syllabus 2a","visibility":"private"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_VISIBILITY"}}`, string(body))
+	})
+
+	t.Run("code not unique", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(1, "A1", 2).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2", strings.NewReader(`{"title":"Syllabus 2a","code":"A1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"CODE_SHOULD_BE_UNIQUE"}}`, string(body))
+	})
+
 	t.Run("update not unique", func(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
@@ -143,6 +183,134 @@ func Test_saveSyllabusStructure(t *testing.T) {
 	})
 }
 
+func Test_sortSyllabusStructures(t *testing.T) {
+	assert := assert.New(t)
+
+	ids, ok := sortSyllabusStructures(map[int]*int{})
+	assert.Empty(ids)
+	assert.True(ok)
+
+	ids, ok = sortSyllabusStructures(map[int]*int{3: testutil.Ptr(1), 1: nil, 4: testutil.Ptr(-1), 2: testutil.Ptr(3)})
+	assert.Equal([]int{1, 3, 2}, ids)
+	assert.True(ok)
+
+	// branch
+	_, ok = sortSyllabusStructures(map[int]*int{1: nil, 2: testutil.Ptr(1), 3: testutil.Ptr(1)})
+	assert.False(ok)
+
+	// loop
+	_, ok = sortSyllabusStructures(map[int]*int{1: nil, 2: testutil.Ptr(3), 3: testutil.Ptr(2)})
+	assert.False(ok)
+
+	// unreachable
+	_, ok = sortSyllabusStructures(map[int]*int{1: nil, 2: testutil.Ptr(5)})
+	assert.False(ok)
+}
+
+func Test_moveSyllabusStructure(t *testing.T) {
+	assert := assert.New(t)
+
+	structures := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "prev_id"}).
+			AddRow(1, nil).
+			AddRow(2, 1).
+			AddRow(3, 2).
+			AddRow(4, -1)
+	}
+
+	t.Run("assignment", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/structures/4/move", strings.NewReader(`{"prevId":null}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"ASSIGNMENT_CANNOT_BE_MOVED"}}`, string(body))
+	})
+
+	t.Run("invalid prevId", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/structures/3/move", strings.NewReader(`{"prevId":4}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_PREV_ID"}}`, string(body))
+	})
+
+	t.Run("hierarchy would break", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "parent_structure_id"}).AddRow(1, 0).AddRow(2, 1))
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/structures/2/move", strings.NewReader(`{"prevId":3}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"SYLLABUS_HIERARCHY_WOULD_BREAK"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "parent_structure_id"}))
+		mock.ExpectExec(`WITH .+ \(3, NULL\), \(1, 3\), \(2, 1\) .+ UPDATE syllabus_structures`).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/structures/3/move", strings.NewReader(`{"prevId":null}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
 func Test_deleteSyllabusStructure(t *testing.T) {
 	assert := assert.New(t)
 
@@ -209,7 +377,10 @@ func Test_syllabuses(t *testing.T) {
 
 	mock.ExpectQuery("SELECT .+ FROM syllabuses").
 		WithArgs("1").
-		WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title"}).AddRow(1, nil, 1, "Syllabus 1"))
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title", "description", "code", "position", "tags", "max_score", "due_at", "visibility"}).
+				AddRow(1, nil, 1, "Syllabus 1", "", "S1", 0, `["a"]`, 100, "2024-01-01 00:00:00", "visible"),
+		)
 
 	app := fiber.New()
 	h.Register(app, middleware.New())
@@ -221,7 +392,72 @@ func Test_syllabuses(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	assert.Equal(t, "1", resp.Header.Get("X-Total-Count"))
 	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"nodes":[{"id":1,"title":"Syllabus 1","parentId":null,"structureId":1}],"error":null}`, string(body))
+	assert.Equal(t, `{"nodes":[{"id":1,"title":"Syllabus 1","parentId":null,"structureId":1,"description":"","code":"S1","position":0,"tags":["a"],"maxScore":100,"dueAt":"2024-01-01T00:00:00Z","visibility":"visible","isLocked":false}],"error":null}`, string(body))
+}
+
+func Test_syllabusTree(t *testing.T) {
+	assert := assert.New(t)
+
+	structures := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "prev_id", "title"}).
+			AddRow(1, nil, "Syllabus").
+			AddRow(2, -1, "Assignment")
+	}
+
+	t.Run("root not found", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("WITH RECURSIVE t AS").
+			WithArgs(1, 3, 3, 0, 0).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title", "child_count"}))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/syllabuses/tree?rootId=3", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("WITH RECURSIVE t AS").
+			WithArgs(1, 0, 0, 2, 2).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "structure_id", "title", "description", "code", "position", "tags", "max_score", "due_at", "visibility", "is_locked", "child_count"}).
+					AddRow(1, nil, 1, "Syllabus 1", "", nil, 0, "[]", nil, nil, "visible", false, 2).
+					AddRow(2, 1, 2, "Assignment 1", "", "A1", 0, "[]", 100, nil, "visible", true, 0).
+					AddRow(3, 1, 2, "Assignment 2", "", "A2", 1, "[]", 100, nil, "visible", false, 0),
+			)
+		mock.ExpectQuery("SELECT COUNT.+ FROM users").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "is_assignment", "score_count"}).
+					AddRow(1, nil, false, 0).
+					AddRow(2, 1, true, 2).
+					AddRow(3, 1, true, 1),
+			)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("GET", "/v1/programs/1/syllabuses/tree?depth=2&stats=true", nil)
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		assert.Equal("3", resp.Header.Get("X-Total-Count"))
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"levels":[{"level":0,"structureId":1,"title":"Syllabus","count":1},{"level":1,"structureId":2,"title":"Assignment","count":2}],"nodes":[{"id":1,"title":"Syllabus 1","parentId":null,"structureId":1,"description":"","code":null,"position":0,"tags":[],"maxScore":null,"dueAt":null,"visibility":"visible","isLocked":false,"level":0,"childCount":2,"stats":{"scoreCount":3,"expectedCount":4,"completion":0.75},"children":[{"id":2,"title":"Assignment 1","parentId":1,"structureId":2,"description":"","code":"A1","position":0,"tags":[],"maxScore":100,"dueAt":null,"visibility":"visible","isLocked":true,"level":1,"childCount":0,"stats":{"scoreCount":2,"expectedCount":2,"completion":1},"children":[]},{"id":3,"title":"Assignment 2","parentId":1,"structureId":2,"description":"","code":"A2","position":1,"tags":[],"maxScore":100,"dueAt":null,"visibility":"visible","isLocked":false,"level":1,"childCount":0,"stats":{"scoreCount":1,"expectedCount":2,"completion":0.5},"children":[]}]}],"error":null}`, string(body))
+	})
 }
 
 func Test_syllabus(t *testing.T) {
@@ -231,10 +467,10 @@ func Test_syllabus(t *testing.T) {
 	mock.ExpectQuery("SELECT .+ FROM syllabuses").
 		WithArgs(3, "1").
 		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "title", "is_assignment"}).
-				AddRow(3, "Syllabus 3", true).
-				AddRow(2, "Syllabus 2", false).
-				AddRow(1, "Syllabus 1", false),
+			sqlmock.NewRows([]string{"id", "title", "description", "code", "position", "tags", "max_score", "due_at", "visibility", "is_assignment"}).
+				AddRow(3, "Syllabus 3", "Description 3", "A3", 1, "[]", 10, nil, "hidden", true).
+				AddRow(2, "Syllabus 2", "", nil, 0, "[]", nil, nil, "visible", false).
+				AddRow(1, "Syllabus 1", "", nil, 0, "[]", nil, nil, "visible", false),
 		)
 
 	app := fiber.New()
@@ -246,7 +482,7 @@ func Test_syllabus(t *testing.T) {
 	assert.Nil(t, mock.ExpectationsWereMet())
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"syllabus":{"id":3,"title":"Syllabus 3","parents":[{"id":1,"title":"Syllabus 1"},{"id":2,"title":"Syllabus 2"}],"isAssignment":true},"error":null}`, string(body))
+	assert.Equal(t, `{"syllabus":{"id":3,"title":"Syllabus 3","description":"Description 3","code":"A3","position":1,"tags":[],"maxScore":10,"dueAt":null,"visibility":"hidden","isLocked":false,"parents":[{"id":1,"title":"Syllabus 1"},{"id":2,"title":"Syllabus 2"}],"isAssignment":true},"error":null}`, string(body))
 }
 
 func Test_saveSyllabus(t *testing.T) {
@@ -256,9 +492,11 @@ func Test_saveSyllabus(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
-		mock.ExpectExec("INSERT INTO syllabuses").
-			WithArgs(nil, 1, "Syllabus 1").
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO syllabuses .+ SELECT COALESCE\(MAX\(s.position\) \+ 1, 0\)`).
+			WithArgs(nil, 1, "Syllabus 1", "", nil, 1, nil, "[]", nil, nil, "visible").
 			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+		mock.ExpectRollback()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -277,9 +515,13 @@ func Test_saveSyllabus(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
-		mock.ExpectExec("INSERT INTO syllabuses").
-			WithArgs(nil, 1, "Syllabus 1").
+		mock.ExpectBegin()
+		mock.ExpectExec(`INSERT INTO syllabuses .+ SELECT COALESCE\(MAX\(s.position\) \+ 1, 0\)`).
+			WithArgs(nil, 1, "Syllabus 1", "", nil, 1, nil, "[]", nil, nil, "visible").
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -298,9 +540,11 @@ func Test_saveSyllabus(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE syllabuses").
-			WithArgs(1, "Syllabus 2a", 2).
+			WithArgs("Syllabus 2a", "", nil, "[]", nil, nil, "visible", 2).
 			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+		mock.ExpectRollback()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
@@ -319,14 +563,125 @@ func Test_saveSyllabus(t *testing.T) {
 		db, mock := db.New()
 		h := New(db, nil)
 
+		mock.ExpectQuery("SELECT EXISTS").
+			WithArgs(1, "A2", 2).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+		mock.ExpectBegin()
 		mock.ExpectExec("UPDATE syllabuses").
-			WithArgs(1, "Syllabus 2a", 2).
+			WithArgs("Syllabus 2a", "Description 2a", "A2", `["quiz"]`, 100.0, "2024-01-01 00:00:00", "hidden", 2).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectCommit()
 
 		app := fiber.New()
 		h.Register(app, middleware.New())
 
-		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2", strings.NewReader(`{"parentId":1,"title":"Syllabus 2a"}`))
+		// The position is ignored, it's only changed by moveSyllabus
+		data := `{"title":"Syllabus 2a","description":"Description 2a","code":" A2 ","position":1,"tags":["quiz"],"maxScore":100,"dueAt":"2024-01-01T07:00:00+07:00","visibility":"hidden"}`
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2", strings.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
+}
+
+func Test_moveSyllabus(t *testing.T) {
+	assert := assert.New(t)
+
+	structures := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "prev_id"}).
+			AddRow(1, nil).
+			AddRow(2, 1).
+			AddRow(3, -1)
+	}
+
+	t.Run("invalid parentId", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "parent_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").WithArgs(4, 1).WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/3/move", strings.NewReader(`{"parentId":4}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_PARENT_ID"}}`, string(body))
+	})
+
+	t.Run("invalid parent level", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(5).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "parent_id"}).AddRow(3, 3))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id"}).AddRow(1))
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/5/move", strings.NewReader(`{"parentId":2}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"INVALID_PARENT_LEVEL"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT .+ FROM syllabus_structures").WithArgs(1).WillReturnRows(structures())
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id", "parent_id"}).AddRow(2, 1))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"structure_id"}).AddRow(1))
+		mock.ExpectExec("UPDATE syllabuses").WithArgs(2, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT s.id FROM syllabuses s").
+			WithArgs(1, 2, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(5))
+		mock.ExpectExec(`WITH .+ \(3, 0\), \(4, 1\), \(5, 2\) .+ UPDATE syllabuses SET position`).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec("WITH t AS .+ FROM scorecard_definitions d .+ UPDATE scorecard_structures").
+			WithArgs(2, 1, 2, 3, 1, 1, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses s").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectExec("WITH RECURSIVE t AS .+ UPDATE scorecards").WithArgs(3, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/programs/1/syllabuses/3/move", strings.NewReader(`{"parentId":2,"beforeId":4}`))
 		req.Header.Set("Content-Type", "application/json")
 
 		resp, _ := app.Test(req)
@@ -451,7 +806,7 @@ func Test_syllabusScores(t *testing.T) {
 
 		mock.ExpectQuery("SELECT .+ FROM users .+ JOIN user_scores").
 			WithArgs("2").
-			WillReturnRows(sqlmock.NewRows([]string{"user_id", "score"}).AddRow(1, nil))
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "score", "feedback", "note"}).AddRow(1, nil, nil, nil))
 
 		mock.ExpectQuery(`SELECT .+ FROM users WHERE id IN \(\?\)`).
 			WithArgs(1).
@@ -467,36 +822,101 @@ func Test_syllabusScores(t *testing.T) {
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 		assert.Equal("1", resp.Header.Get("X-Total-Count"))
 		body, _ := io.ReadAll(resp.Body)
-		assert.Equal(`{"nodes":[{"user":{"id":1,"name":"User 1"},"score":null}],"error":null}`, string(body))
+		assert.Equal(`{"nodes":[{"user":{"id":1,"name":"User 1"},"score":null,"feedback":null,"note":null}],"error":null}`, string(body))
 	})
 
 }
 
 func Test_saveScore(t *testing.T) {
-	db, mock := db.New()
-	h := New(db, nil)
+	assert := assert.New(t)
 
-	mock.ExpectBegin()
+	expectValidateScore := func(mock sqlmock.Sqlmock, maxScore any, locked ...int) {
+		mock.ExpectQuery("SELECT id FROM users").
+			WithArgs(1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("SELECT .+ FROM syllabuses").
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "max_score", "is_assignment"}).AddRow(2, maxScore, true))
+		rows := sqlmock.NewRows([]string{"id"})
+		for _, id := range locked {
+			rows.AddRow(id)
+		}
+		mock.ExpectQuery("WITH RECURSIVE t AS .+ s.is_locked = TRUE").
+			WithArgs(1).
+			WillReturnRows(rows)
+	}
 
-	mock.ExpectExec("INSERT INTO user_scores").
-		WithArgs(3, "2", float64(100)).
-		WillReturnResult(sqlmock.NewResult(1, 1))
+	t.Run("locked", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
 
-	mock.ExpectExec("UPDATE scorecards").
-		WithArgs(3).
-		WillReturnResult(sqlmock.NewResult(0, 1))
+		expectValidateScore(mock, nil, 1, 2)
 
-	mock.ExpectCommit()
+		app := fiber.New()
+		h.Register(app, middleware.New())
 
-	app := fiber.New()
-	h.Register(app, middleware.New())
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2/scores/3", strings.NewReader(`{"score":100}`))
+		req.Header.Set("Content-Type", "application/json")
 
-	req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2/scores/3", strings.NewReader(`{"score":100}`))
-	req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusLocked, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"SYLLABUS_IS_LOCKED"}}`, string(body))
+	})
 
-	resp, _ := app.Test(req)
-	assert.Nil(t, mock.ExpectationsWereMet())
-	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
-	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+	t.Run("exceeds max score", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectValidateScore(mock, 50)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2/scores/3", strings.NewReader(`{"score":100}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"error":{"code":"SCORE_EXCEEDS_MAX_SCORE"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		expectValidateScore(mock, 100, 1)
+
+		mock.ExpectBegin()
+
+		mock.ExpectExec("INSERT INTO score_events").
+			WithArgs(3, 2, float64(100), 1, "Admin", "Regraded").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		mock.ExpectExec("INSERT INTO user_scores .+ ON CONFLICT").
+			WithArgs(3, 2, float64(100), "Good job", "").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(1, 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/programs/1/syllabuses/2/scores/3", strings.NewReader(`{"score":100,"feedback":"Good job","reason":"Regraded"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "Admin")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"error":null}`, string(body))
+	})
 }
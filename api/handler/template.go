@@ -0,0 +1,205 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/brantem/scorecard/template"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/rs/zerolog/log"
+)
+
+// ?name string
+
+func (h *Handler) templates(c *fiber.Ctx) error {
+	var result struct {
+		Nodes []*model.Template `json:"nodes"`
+		Error any               `json:"error"`
+	}
+	result.Nodes = []*model.Template{}
+
+	name := c.Query("name")
+	rows, err := h.db.QueryxContext(c.UserContext(), `
+		SELECT id, name, version, created_at
+		FROM templates
+		WHERE (? = '' OR name = ?)
+		ORDER BY name ASC, version DESC
+	`, name, name)
+	if err != nil {
+		log.Error().Err(err).Msg("template.templates")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var node model.Template
+		if err := rows.StructScan(&node); err != nil {
+			log.Error().Err(err).Msg("template.templates")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+		result.Nodes = append(result.Nodes, &node)
+	}
+	c.Set("X-Total-Count", strconv.Itoa(len(result.Nodes)))
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) template(c *fiber.Ctx) error {
+	var result struct {
+		Template *model.Template `json:"template"`
+		Error    any             `json:"error"`
+	}
+
+	var node model.Template
+	var document string
+	err := h.db.QueryRowContext(c.UserContext(), `
+		SELECT id, name, version, document, created_at
+		FROM templates
+		WHERE id = ?
+	`, c.Params("templateId")).Scan(&node.ID, &node.Name, &node.Version, &document, &node.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("template.template")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+	node.Document = json.RawMessage(document)
+	result.Template = &node
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+// saveTemplate never changes an existing template, saving a template always creates a new version of it. Without a
+// templateId, the version is added to the template with the same name, or a new template is started.
+func (h *Handler) saveTemplate(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		ID      int  `json:"id"`
+		Version int  `json:"version"`
+		Error   any  `json:"error"`
+	}
+
+	var body struct {
+		Name     string          `json:"name"`
+		Document json.RawMessage `json:"document"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("template.saveTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if _, err := template.Parse(body.Document); err != nil {
+		result.Error = fiber.Map{"code": "INVALID_DOCUMENT", "message": err.Error()}
+		return c.Status(fiber.StatusBadRequest).JSON(result)
+	}
+
+	if templateID, _ := c.ParamsInt("templateId"); templateID != 0 {
+		err := h.db.QueryRowContext(c.UserContext(), `SELECT name FROM templates WHERE id = ?`, templateID).Scan(&body.Name)
+		if err != nil {
+			log.Error().Err(err).Msg("template.saveTemplate")
+			result.Error = constant.RespInternalServerError
+			return c.Status(fiber.StatusInternalServerError).JSON(result)
+		}
+	}
+
+	err := h.db.QueryRowContext(c.UserContext(), `
+		INSERT INTO templates (name, version, document)
+		SELECT ?, COALESCE(MAX(version), 0) + 1, ?
+		FROM templates
+		WHERE name = ?
+		RETURNING id, version
+	`, body.Name, string(body.Document), body.Name).Scan(&result.ID, &result.Version)
+	if err != nil {
+		if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			result.Error = fiber.Map{"code": "VERSION_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+		log.Error().Err(err).Msg("template.saveTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) deleteTemplate(c *fiber.Ctx) error {
+	var result struct {
+		Success bool `json:"success"`
+		Error   any  `json:"error"`
+	}
+
+	if _, err := h.db.ExecContext(c.UserContext(), `DELETE FROM templates WHERE id = ?`, c.Params("templateId")); err != nil {
+		log.Error().Err(err).Msg("template.deleteTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	result.Success = true
+	return c.Status(fiber.StatusOK).JSON(result)
+}
+
+func (h *Handler) instantiateTemplate(c *fiber.Ctx) error {
+	var result struct {
+		Success   bool `json:"success"`
+		ProgramID int  `json:"programId"`
+		Error     any  `json:"error"`
+	}
+
+	var body struct {
+		Title string `json:"title"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		log.Error().Err(err).Msg("template.instantiateTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var document string
+	err := h.db.QueryRowContext(c.UserContext(), `SELECT document FROM templates WHERE id = ?`, c.Params("templateId")).Scan(&document)
+	if err != nil {
+		log.Error().Err(err).Msg("template.instantiateTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	doc, err := template.Parse([]byte(document))
+	if err != nil {
+		log.Error().Err(err).Msg("template.instantiateTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx := h.db.MustBeginTx(c.UserContext(), nil)
+
+	var programID int
+	err = tx.QueryRowContext(c.UserContext(), `INSERT INTO programs (title) VALUES (?) RETURNING id`, body.Title).Scan(&programID)
+	if err != nil {
+		tx.Rollback()
+		if err, ok := err.(sqlite3.Error); ok && err.ExtendedCode == sqlite3.ErrConstraintUnique {
+			result.Error = fiber.Map{"code": "TITLE_SHOULD_BE_UNIQUE"}
+			return c.Status(fiber.StatusConflict).JSON(result)
+		}
+		log.Error().Err(err).Msg("template.instantiateTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if err := template.Instantiate(c.UserContext(), tx, programID, doc); err != nil {
+		tx.Rollback()
+		log.Error().Err(err).Msg("template.instantiateTemplate")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	tx.Commit()
+
+	result.Success = true
+	result.ProgramID = programID
+	return c.Status(fiber.StatusOK).JSON(result)
+}
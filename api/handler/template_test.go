@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+const templateDocument = `{"syllabusStructures":["Syllabus"],"syllabuses":[{"title":"Syllabus 1","children":[{"title":"Assignment 1"}]}],"scorecardStructures":[{"title":"Syllabus 1","syllabus":["Syllabus 1"]}]}`
+
+func Test_templates(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM templates").
+		WithArgs("Template 1", "Template 1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "version", "created_at"}).AddRow(2, "Template 1", 2, "2024-01-01 00:00:00"))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/templates?name=Template+1", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("X-Total-Count"))
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"nodes":[{"id":2,"name":"Template 1","version":2,"createdAt":"2024-01-01T00:00:00Z"}],"error":null}`, string(body))
+}
+
+func Test_template(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectQuery("SELECT .+ FROM templates").
+		WithArgs("1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "version", "document", "created_at"}).AddRow(1, "Template 1", 1, templateDocument, "2024-01-01 00:00:00"))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/templates/1", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"template":{"id":1,"name":"Template 1","version":1,"document":`+templateDocument+`,"createdAt":"2024-01-01T00:00:00Z"},"error":null}`, string(body))
+}
+
+func Test_saveTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("invalid document", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/templates", strings.NewReader(`{"name":"Template 1","document":{"syllabuses":[]}}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(string(body), `"code":"INVALID_DOCUMENT"`)
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("INSERT INTO templates").
+			WithArgs("Template 1", templateDocument, "Template 1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(1, 1))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/templates", strings.NewReader(`{"name":"Template 1","document":`+templateDocument+`}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"id":1,"version":1,"error":null}`, string(body))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT name FROM templates").
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("Template 1"))
+		mock.ExpectQuery("INSERT INTO templates").
+			WithArgs("Template 1", templateDocument, "Template 1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "version"}).AddRow(2, 2))
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("PUT", "/v1/templates/1", strings.NewReader(`{"document":`+templateDocument+`}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"id":2,"version":2,"error":null}`, string(body))
+	})
+}
+
+func Test_deleteTemplate(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	mock.ExpectExec("DELETE FROM templates").
+		WithArgs("1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("DELETE", "/v1/templates/1", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"success":true,"error":null}`, string(body))
+}
+
+func Test_instantiateTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("not unique", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT document FROM templates").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"document"}).AddRow(templateDocument))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").
+			WithArgs("Program 1").
+			WillReturnError(sqlite3.Error{ExtendedCode: sqlite3.ErrConstraintUnique})
+		mock.ExpectRollback()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/templates/1/instantiate", strings.NewReader(`{"title":"Program 1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusConflict, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":false,"programId":0,"error":{"code":"TITLE_SHOULD_BE_UNIQUE"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		h := New(db, nil)
+
+		mock.ExpectQuery("SELECT document FROM templates").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"document"}).AddRow(templateDocument))
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO programs").WithArgs("Program 1").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2, nil, "Syllabus").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("INSERT INTO syllabuses").WithArgs(nil, 1, "Syllabus 1", 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("INSERT INTO syllabuses").WithArgs(3, 2, "Assignment 1", 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectQuery("INSERT INTO scorecard_definitions").WithArgs(2, "Scorecard", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").WithArgs(2, 6, nil, "Syllabus 1", 3, 0, 1.0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectCommit()
+
+		app := fiber.New()
+		h.Register(app, middleware.New())
+
+		req := httptest.NewRequest("POST", "/v1/templates/1/instantiate", strings.NewReader(`{"title":"Program 1"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, _ := app.Test(req)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"success":true,"programId":2,"error":null}`, string(body))
+	})
+}
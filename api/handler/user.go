@@ -146,6 +146,8 @@ func (h *Handler) userScores(c *fiber.Ctx) error {
 	type Node struct {
 		Syllabus SyllabusWithParents `json:"syllabus"`
 		Score    *float64            `json:"score"`
+		Feedback *string             `json:"feedback"`
+		Note     *string             `json:"note"`
 	}
 
 	var result struct {
@@ -154,7 +156,7 @@ func (h *Handler) userScores(c *fiber.Ctx) error {
 	}
 
 	rows, err := h.db.QueryxContext(c.UserContext(), `
-		SELECT s.id, s.parent_id, s.title, us.score, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
+		SELECT s.id, s.parent_id, s.title, us.score, us.feedback, us.note, COALESCE(ss.prev_id, 0) = -1 AS is_assignment
 		FROM syllabus_structures ss
 		JOIN syllabuses s ON s.structure_id = ss.id
 		LEFT JOIN user_scores us ON us.user_id = ? AND us.syllabus_id = s.id
@@ -172,7 +174,9 @@ func (h *Handler) userScores(c *fiber.Ctx) error {
 		var row struct {
 			SyllabusWithParentID
 			Score        *float64 `json:"score"`
-			IsAssignment bool     `db:"is_assignment"`
+			Feedback     *string
+			Note         *string
+			IsAssignment bool `db:"is_assignment"`
 		}
 		if err := rows.StructScan(&row); err != nil {
 			log.Error().Err(err).Msg("user.users")
@@ -184,6 +188,8 @@ func (h *Handler) userScores(c *fiber.Ctx) error {
 			result.Nodes = append(result.Nodes, &Node{
 				Syllabus: SyllabusWithParents{row.SyllabusWithParentID, nil},
 				Score:    row.Score,
+				Feedback: row.Feedback,
+				Note:     row.Note,
 			})
 		} else {
 			m[row.ID] = &row.SyllabusWithParentID
@@ -141,9 +141,9 @@ func Test_userScores(t *testing.T) {
 	mock.ExpectQuery("SELECT .+ FROM syllabus_structures").
 		WithArgs("1", "1").
 		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "parent_id", "title", "score", "is_assignment"}).
-				AddRow(1, nil, "Syllabus 1", 0, false).
-				AddRow(2, 1, "Syllabus 2", 100, true),
+			sqlmock.NewRows([]string{"id", "parent_id", "title", "score", "feedback", "note", "is_assignment"}).
+				AddRow(1, nil, "Syllabus 1", 0, nil, nil, false).
+				AddRow(2, 1, "Syllabus 2", 100, "Good job", "", true),
 		)
 
 	app := fiber.New()
@@ -156,7 +156,7 @@ func Test_userScores(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 	assert.Equal(t, "1", resp.Header.Get("X-Total-Count"))
 	body, _ := io.ReadAll(resp.Body)
-	assert.Equal(t, `{"nodes":[{"syllabus":{"id":2,"title":"Syllabus 2","parents":[{"id":1,"title":"Syllabus 1"}]},"score":100}],"error":null}`, string(body))
+	assert.Equal(t, `{"nodes":[{"syllabus":{"id":2,"title":"Syllabus 2","parents":[{"id":1,"title":"Syllabus 1"}]},"score":100,"feedback":"Good job","note":""}],"error":null}`, string(body))
 }
 
 func Test_saveUser(t *testing.T) {
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"github.com/brantem/scorecard/constant"
+	"github.com/brantem/scorecard/model"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// validateScorecardStructures reports the problems in the scorecard structures that change the generated scores
+// without failing the generation
+func (h *Handler) validateScorecardStructures(c *fiber.Ctx) error {
+	var result struct {
+		Findings []*model.ScorecardStructureFinding `json:"findings"`
+		Error    any                                `json:"error"`
+	}
+	result.Findings = []*model.ScorecardStructureFinding{}
+
+	programID, _ := c.ParamsInt("programId")
+
+	definitionID, err := h.getScorecardDefinitionID(c)
+	if err != nil {
+		if err == constant.ErrNotFound {
+			result.Error = constant.RespNotFound
+			return c.Status(fiber.StatusNotFound).JSON(result)
+		}
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var structures []*struct {
+		ID                int
+		ParentID          *int `db:"parent_id"`
+		SyllabusID        *int `db:"syllabus_id"`
+		ParentProgramID   *int `db:"parent_program_id"`
+		SyllabusProgramID *int `db:"syllabus_program_id"`
+		IsAssignment      bool `db:"is_assignment"`
+	}
+	err = h.db.SelectContext(c.UserContext(), &structures, `
+		SELECT ss.id, ss.parent_id, ss.syllabus_id, p.program_id AS parent_program_id,
+		       sst.program_id AS syllabus_program_id, COALESCE(sst.prev_id, 0) = -1 AS is_assignment
+		FROM scorecard_structures ss
+		LEFT JOIN scorecard_structures p ON p.id = ss.parent_id
+		LEFT JOIN syllabuses s ON s.id = ss.syllabus_id
+		LEFT JOIN syllabus_structures sst ON sst.id = s.structure_id
+		WHERE ss.definition_id = ?
+		ORDER BY ss.position, ss.rowid
+	`, definitionID)
+	if err != nil {
+		log.Error().Err(err).Msg("validation.validateScorecardStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	var assignmentIds []int
+	err = h.db.SelectContext(c.UserContext(), &assignmentIds, `
+		SELECT s.id
+		FROM syllabuses s
+		JOIN syllabus_structures ss ON ss.id = s.structure_id
+		WHERE ss.program_id = ?
+		  AND ss.prev_id = -1
+		ORDER BY s.position, s.rowid
+	`, programID)
+	if err != nil {
+		log.Error().Err(err).Msg("validation.validateScorecardStructures")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	add := func(code, severity string, structureID, syllabusID *int, message, suggestion string) {
+		result.Findings = append(result.Findings, &model.ScorecardStructureFinding{
+			Code:        code,
+			Severity:    severity,
+			StructureID: structureID,
+			SyllabusID:  syllabusID,
+			Message:     message,
+			Suggestion:  suggestion,
+		})
+	}
+
+	// Only the structures of this program are used by the generator, a structure under a parent from another program
+	// is never reached
+	children := make(map[int][]int)
+	hasAssignment := make(map[int]bool)
+	for _, structure := range structures {
+		isOwnSyllabus := structure.SyllabusProgramID != nil && *structure.SyllabusProgramID == programID
+		hasAssignment[structure.ID] = isOwnSyllabus && structure.IsAssignment
+		if structure.ParentID != nil && *structure.ParentProgramID == programID {
+			children[*structure.ParentID] = append(children[*structure.ParentID], structure.ID)
+		}
+	}
+
+	var fill func(id int) bool
+	fill = func(id int) bool {
+		for _, childID := range children[id] {
+			if fill(childID) {
+				hasAssignment[id] = true
+			}
+		}
+		return hasAssignment[id]
+	}
+	for _, structure := range structures {
+		fill(structure.ID)
+	}
+
+	mappedBy := make(map[int]int) // syllabusID -> structureID
+	for _, structure := range structures {
+		id := structure.ID
+		isLeaf := len(children[id]) == 0
+
+		if structure.ParentID != nil && *structure.ParentProgramID != programID {
+			add("PARENT_IN_OTHER_PROGRAM", model.FindingSeverityError, &id, structure.SyllabusID,
+				"The parent belongs to another program, so the structure is left out of the scorecards.",
+				"Move the structure under a structure of this program or make it a root.")
+		}
+
+		switch {
+		case structure.SyllabusID == nil:
+			if isLeaf {
+				add("LEAF_WITHOUT_SYLLABUS", model.FindingSeverityWarning, &id, nil,
+					"The structure has no syllabus and nothing under it, so it always counts as 0.",
+					"Map the structure to an assignment or delete it.")
+			}
+		case *structure.SyllabusProgramID != programID:
+			add("SYLLABUS_IN_OTHER_PROGRAM", model.FindingSeverityError, &id, structure.SyllabusID,
+				"The syllabus belongs to another program, so the structure counts as 0.",
+				"Map the structure to a syllabus of this program.")
+		case !structure.IsAssignment && isLeaf:
+			add("NON_ASSIGNMENT_SYLLABUS", model.FindingSeverityError, &id, structure.SyllabusID,
+				"The syllabus isn't an assignment and doesn't have scores, so the structure counts as 0.",
+				"Map the structure to an assignment or copy the syllabus into the structures.")
+		case !structure.IsAssignment:
+			add("NON_ASSIGNMENT_SYLLABUS", model.FindingSeverityInfo, &id, structure.SyllabusID,
+				"The syllabus isn't an assignment, the score of the structure comes from the structures under it.",
+				"Nothing has to be changed, the syllabus only describes the structure.")
+		default:
+			if _, ok := mappedBy[*structure.SyllabusID]; ok {
+				add("DUPLICATE_SYLLABUS", model.FindingSeverityWarning, &id, structure.SyllabusID,
+					"Another structure is mapped to the same assignment, so the assignment is counted more than once.",
+					"Delete the duplicate or map it to another assignment.")
+			} else {
+				mappedBy[*structure.SyllabusID] = id
+			}
+		}
+
+		if !isLeaf && !hasAssignment[id] {
+			add("EMPTY_PARENT", model.FindingSeverityWarning, &id, nil,
+				"None of the structures under it is mapped to an assignment, so the structure always counts as 0.",
+				"Map the structures under it to assignments or delete it.")
+		}
+	}
+
+	for _, assignmentID := range assignmentIds {
+		if _, ok := mappedBy[assignmentID]; !ok {
+			id := assignmentID
+			add("UNREFERENCED_ASSIGNMENT", model.FindingSeverityWarning, nil, &id,
+				"No structure is mapped to the assignment, so its scores aren't in the scorecards.",
+				"Add a structure for the assignment or copy its syllabus into the structures.")
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(result)
+}
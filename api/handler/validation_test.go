@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/brantem/scorecard/testutil/middleware"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_validateScorecardStructures(t *testing.T) {
+	db, mock := db.New()
+	h := New(db, nil)
+
+	expectScorecardDefinitionID(mock, 3)
+	mock.ExpectQuery("SELECT .+ FROM scorecard_structures ss").
+		WithArgs(3).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "parent_program_id", "syllabus_program_id", "is_assignment"}).
+				AddRow(1, nil, nil, nil, nil, false).
+				AddRow(2, 1, 10, 1, 1, true).
+				AddRow(3, 1, 10, 1, 1, true).         // duplicate
+				AddRow(4, nil, nil, nil, nil, false). // leaf without syllabus
+				AddRow(5, nil, 20, nil, 1, false).    // non-assignment leaf
+				AddRow(6, nil, 20, nil, 1, false).    // non-assignment parent, empty
+				AddRow(7, 6, nil, 1, nil, false).
+				AddRow(8, 99, 30, 2, 2, true), // other program
+		)
+	mock.ExpectQuery("SELECT s.id FROM syllabuses s").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10).AddRow(11))
+
+	app := fiber.New()
+	h.Register(app, middleware.New())
+
+	req := httptest.NewRequest("GET", "/v1/programs/1/scorecards/structures/validate", nil)
+
+	resp, _ := app.Test(req)
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, `{"findings":[{"code":"DUPLICATE_SYLLABUS","severity":"warning","structureId":3,"syllabusId":10,"message":"Another structure is mapped to the same assignment, so the assignment is counted more than once.","suggestion":"Delete the duplicate or map it to another assignment."},{"code":"LEAF_WITHOUT_SYLLABUS","severity":"warning","structureId":4,"syllabusId":null,"message":"The structure has no syllabus and nothing under it, so it always counts as 0.","suggestion":"Map the structure to an assignment or delete it."},{"code":"NON_ASSIGNMENT_SYLLABUS","severity":"error","structureId":5,"syllabusId":20,"message":"The syllabus isn't an assignment and doesn't have scores, so the structure counts as 0.","suggestion":"Map the structure to an assignment or copy the syllabus into the structures."},{"code":"NON_ASSIGNMENT_SYLLABUS","severity":"info","structureId":6,"syllabusId":20,"message":"The syllabus isn't an assignment, the score of the structure comes from the structures under it.","suggestion":"Nothing has to be changed, the syllabus only describes the structure."},{"code":"EMPTY_PARENT","severity":"warning","structureId":6,"syllabusId":null,"message":"None of the structures under it is mapped to an assignment, so the structure always counts as 0.","suggestion":"Map the structures under it to assignments or delete it."},{"code":"LEAF_WITHOUT_SYLLABUS","severity":"warning","structureId":7,"syllabusId":null,"message":"The structure has no syllabus and nothing under it, so it always counts as 0.","suggestion":"Map the structure to an assignment or delete it."},{"code":"PARENT_IN_OTHER_PROGRAM","severity":"error","structureId":8,"syllabusId":30,"message":"The parent belongs to another program, so the structure is left out of the scorecards.","suggestion":"Move the structure under a structure of this program or make it a root."},{"code":"SYLLABUS_IN_OTHER_PROGRAM","severity":"error","structureId":8,"syllabusId":30,"message":"The syllabus belongs to another program, so the structure counts as 0.","suggestion":"Map the structure to a syllabus of this program."},{"code":"UNREFERENCED_ASSIGNMENT","severity":"warning","structureId":null,"syllabusId":11,"message":"No structure is mapped to the assignment, so its scores aren't in the scorecards.","suggestion":"Add a structure for the assignment or copy its syllabus into the structures."}],"error":null}`, string(body))
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"github.com/brantem/scorecard/constant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+func (m *Middleware) GradeScale(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	gradeScaleID, _ := c.ParamsInt("gradeScaleId")
+	switch {
+	case gradeScaleID < 0:
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	case gradeScaleID == 0:
+		return c.Next()
+	}
+
+	// In a real production app, this should be cached
+
+	var isExists bool
+	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
+	  SELECT id
+	  FROM grade_scales
+	  WHERE id = ?
+	    AND program_id = ?
+	)`, gradeScaleID, c.Params("programId")).Scan(&isExists)
+	if err != nil {
+		log.Error().Err(err).Msg("middleware.GradeScale")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if !isExists {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/brantem/scorecard/constant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// NotFinalized rejects the request with 423 Locked if the program is finalized
+func (m *Middleware) NotFinalized(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	var isFinalized bool
+	err := m.db.QueryRowContext(c.UserContext(), `SELECT is_finalized FROM programs WHERE id = ?`, c.Params("programId")).Scan(&isFinalized)
+	if err != nil {
+		log.Error().Err(err).Msg("middleware.NotFinalized")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if isFinalized {
+		result.Error = fiber.Map{"code": "PROGRAM_IS_FINALIZED"}
+		return c.Status(fiber.StatusLocked).JSON(result)
+	}
+
+	return c.Next()
+}
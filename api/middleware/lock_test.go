@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotFinalized(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("finalized", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT is_finalized FROM programs").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"is_finalized"}).AddRow(true))
+
+		app := fiber.New()
+		app.Get("/:programId", m.NotFinalized, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusLocked, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"PROGRAM_IS_FINALIZED"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT is_finalized FROM programs").
+			WithArgs("1").
+			WillReturnRows(sqlmock.NewRows([]string{"is_finalized"}).AddRow(false))
+
+		app := fiber.New()
+		app.Get("/:programId", m.NotFinalized, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+	})
+}
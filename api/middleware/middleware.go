@@ -10,8 +10,13 @@ type MiddlewareInterface interface {
 	User(c *fiber.Ctx) error
 	SyllabusStructure(c *fiber.Ctx) error
 	Syllabus(c *fiber.Ctx) error
+	ScorecardDefinition(c *fiber.Ctx) error
 	ScorecardStructure(c *fiber.Ctx) error
 	Scorecard(c *fiber.Ctx) error
+	ScorecardSchedule(c *fiber.Ctx) error
+	GradeScale(c *fiber.Ctx) error
+	Template(c *fiber.Ctx) error
+	NotFinalized(c *fiber.Ctx) error
 }
 
 type Middleware struct {
@@ -6,6 +6,43 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+func (m *Middleware) ScorecardDefinition(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	definitionID, _ := c.ParamsInt("definitionId")
+	switch {
+	case definitionID < 0:
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	case definitionID == 0:
+		return c.Next()
+	}
+
+	// In a real production app, this should be cached
+
+	var isExists bool
+	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
+	  SELECT id
+	  FROM scorecard_definitions
+	  WHERE id = ?
+	    AND program_id = ?
+	)`, definitionID, c.Params("programId")).Scan(&isExists)
+	if err != nil {
+		log.Error().Err(err).Msg("middleware.ScorecardDefinition")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if !isExists {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	return c.Next()
+}
+
 func (m *Middleware) ScorecardStructure(c *fiber.Ctx) error {
 	var result struct {
 		Error any `json:"error"`
@@ -22,13 +59,17 @@ func (m *Middleware) ScorecardStructure(c *fiber.Ctx) error {
 
 	// In a real production app, this should be cached
 
+	// The routes outside of a definition don't have a definitionId
+	definitionID, _ := c.ParamsInt("definitionId")
+
 	var isExists bool
 	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
 	  SELECT id
 	  FROM scorecard_structures
 	  WHERE id = ?
 	    AND program_id = ?
-	)`, structureID, c.Params("programId")).Scan(&isExists)
+	    AND (? = 0 OR definition_id = ?)
+	)`, structureID, c.Params("programId"), definitionID, definitionID).Scan(&isExists)
 	if err != nil {
 		log.Error().Err(err).Msg("middleware.ScorecardStructure")
 		result.Error = constant.RespInternalServerError
@@ -59,13 +100,17 @@ func (m *Middleware) Scorecard(c *fiber.Ctx) error {
 
 	// In a real production app, this should be cached
 
+	// The routes outside of a definition don't have a definitionId
+	definitionID, _ := c.ParamsInt("definitionId")
+
 	var isExists bool
 	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
 	  SELECT id
 	  FROM scorecards
 	  WHERE id = ?
 	    AND program_id = ?
-	)`, scorecardID, c.Params("programId")).Scan(&isExists)
+	    AND (? = 0 OR definition_id = ?)
+	)`, scorecardID, c.Params("programId"), definitionID, definitionID).Scan(&isExists)
 	if err != nil {
 		log.Error().Err(err).Msg("middleware.Scorecard")
 		result.Error = constant.RespInternalServerError
@@ -79,3 +124,40 @@ func (m *Middleware) Scorecard(c *fiber.Ctx) error {
 
 	return c.Next()
 }
+
+func (m *Middleware) ScorecardSchedule(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	scheduleID, _ := c.ParamsInt("scheduleId")
+	switch {
+	case scheduleID < 0:
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	case scheduleID == 0:
+		return c.Next()
+	}
+
+	// In a real production app, this should be cached
+
+	var isExists bool
+	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
+	  SELECT id
+	  FROM scorecard_schedules
+	  WHERE id = ?
+	    AND program_id = ?
+	)`, scheduleID, c.Params("programId")).Scan(&isExists)
+	if err != nil {
+		log.Error().Err(err).Msg("middleware.ScorecardSchedule")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if !isExists {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	return c.Next()
+}
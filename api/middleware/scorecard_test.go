@@ -11,6 +11,80 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func TestScorecardDefinition(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("definitionId < 0", func(t *testing.T) {
+		m := Middleware{}
+
+		app := fiber.New()
+		app.Get("/:definitionId", m.ScorecardDefinition, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/-1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"NOT_FOUND"}}`, string(body))
+	})
+
+	t.Run("definitionId == 0", func(t *testing.T) {
+		m := Middleware{}
+
+		app := fiber.New()
+		app.Get("/:definitionId", m.ScorecardDefinition, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/0", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_definitions").
+			WithArgs(1, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		app := fiber.New()
+		app.Get("/:programId/:definitionId", m.ScorecardDefinition, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"NOT_FOUND"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_definitions").
+			WithArgs(1, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		app := fiber.New()
+		app.Get("/:programId/:definitionId", m.ScorecardDefinition, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+	})
+}
+
 func TestScorecardStructure(t *testing.T) {
 	assert := assert.New(t)
 
@@ -49,7 +123,7 @@ func TestScorecardStructure(t *testing.T) {
 		m := Middleware{db}
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(1, "1").
+			WithArgs(1, "1", 0, 0).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 		app := fiber.New()
@@ -70,7 +144,7 @@ func TestScorecardStructure(t *testing.T) {
 		m := Middleware{db}
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(1, "1").
+			WithArgs(1, "1", 0, 0).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
 		app := fiber.New()
@@ -83,6 +157,25 @@ func TestScorecardStructure(t *testing.T) {
 		resp, _ := app.Test(req, -1)
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 	})
+
+	t.Run("other definition", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(1, "1", 2, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		app := fiber.New()
+		app.Get("/:programId/:definitionId/:structureId", m.ScorecardStructure, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1/2/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+	})
 }
 
 func TestScorecard(t *testing.T) {
@@ -123,7 +216,7 @@ func TestScorecard(t *testing.T) {
 		m := Middleware{db}
 
 		mock.ExpectQuery("SELECT .+ FROM scorecards").
-			WithArgs(1, "1").
+			WithArgs(1, "1", 0, 0).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 		app := fiber.New()
@@ -144,7 +237,7 @@ func TestScorecard(t *testing.T) {
 		m := Middleware{db}
 
 		mock.ExpectQuery("SELECT .+ FROM scorecards").
-			WithArgs(1, "1").
+			WithArgs(1, "1", 0, 0).
 			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
 		app := fiber.New()
@@ -158,3 +251,77 @@ func TestScorecard(t *testing.T) {
 		assert.Equal(fiber.StatusOK, resp.StatusCode)
 	})
 }
+
+func TestScorecardSchedule(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("scheduleId < 0", func(t *testing.T) {
+		m := Middleware{}
+
+		app := fiber.New()
+		app.Get("/:scheduleId", m.ScorecardSchedule, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/-1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"NOT_FOUND"}}`, string(body))
+	})
+
+	t.Run("scheduleId == 0", func(t *testing.T) {
+		m := Middleware{}
+
+		app := fiber.New()
+		app.Get("/:scheduleId", m.ScorecardSchedule, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/0", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_schedules").
+			WithArgs(1, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+		app := fiber.New()
+		app.Get("/:programId/:scheduleId", m.ScorecardSchedule, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusNotFound, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(`{"error":{"code":"NOT_FOUND"}}`, string(body))
+	})
+
+	t.Run("success", func(t *testing.T) {
+		db, mock := db.New()
+		m := Middleware{db}
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_schedules").
+			WithArgs(1, "1").
+			WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+		app := fiber.New()
+		app.Get("/:programId/:scheduleId", m.ScorecardSchedule, func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/1/1", nil)
+
+		resp, _ := app.Test(req, -1)
+		assert.Equal(fiber.StatusOK, resp.StatusCode)
+	})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/brantem/scorecard/constant"
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog/log"
+)
+
+func (m *Middleware) Template(c *fiber.Ctx) error {
+	var result struct {
+		Error any `json:"error"`
+	}
+
+	templateID, _ := c.ParamsInt("templateId")
+	switch {
+	case templateID < 0:
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	case templateID == 0:
+		return c.Next()
+	}
+
+	// In a real production app, this should be cached
+
+	var isExists bool
+	err := m.db.QueryRowContext(c.UserContext(), `SELECT EXISTS (
+	  SELECT id
+	  FROM templates
+	  WHERE id = ?
+	)`, templateID).Scan(&isExists)
+	if err != nil {
+		log.Error().Err(err).Msg("middleware.Template")
+		result.Error = constant.RespInternalServerError
+		return c.Status(fiber.StatusInternalServerError).JSON(result)
+	}
+
+	if !isExists {
+		result.Error = constant.RespNotFound
+		return c.Status(fiber.StatusNotFound).JSON(result)
+	}
+
+	return c.Next()
+}
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS scorecard_schedules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  expression TEXT NOT NULL,
+  is_enabled INTEGER NOT NULL DEFAULT 1,
+  last_run_at INTEGER,
+  created_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER IF NOT EXISTS scorecard_schedules_updated_at
+AFTER UPDATE OF program_id, expression, is_enabled ON scorecard_schedules
+FOR EACH ROW
+BEGIN
+  UPDATE scorecard_schedules
+  SET updated_at = CURRENT_TIMESTAMP
+  WHERE id = OLD.id;
+END;
@@ -0,0 +1,7 @@
+ALTER TABLE syllabuses ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE syllabuses ADD COLUMN code TEXT;
+ALTER TABLE syllabuses ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE syllabuses ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE syllabuses ADD COLUMN max_score REAL;
+ALTER TABLE syllabuses ADD COLUMN due_at INTEGER;
+ALTER TABLE syllabuses ADD COLUMN visibility TEXT NOT NULL DEFAULT 'visible' CHECK (visibility IN ('visible', 'hidden'));
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS report_templates (
+  program_id INTEGER PRIMARY KEY,
+  header TEXT NOT NULL DEFAULT '',
+  footer TEXT NOT NULL DEFAULT '',
+  logo BLOB,
+  logo_type TEXT CHECK (logo_type IN ('png', 'jpg')),
+  updated_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
@@ -0,0 +1,2 @@
+ALTER TABLE user_scores ADD COLUMN feedback TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_scores ADD COLUMN note TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,22 @@
+-- user_id and syllabus_id aren't foreign keys, so the history is kept after a user or a syllabus is deleted
+CREATE TABLE IF NOT EXISTS score_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  syllabus_id INTEGER NOT NULL,
+  old_score REAL,
+  new_score REAL NOT NULL,
+  actor TEXT NOT NULL DEFAULT '',
+  reason TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS score_events_user_id_syllabus_id ON score_events (user_id, syllabus_id);
+
+CREATE TRIGGER IF NOT EXISTS score_events_append_only
+BEFORE UPDATE ON score_events
+FOR EACH ROW
+BEGIN
+  SELECT RAISE(ABORT, 'score_events is append-only');
+END;
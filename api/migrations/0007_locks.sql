@@ -0,0 +1,13 @@
+ALTER TABLE syllabuses ADD COLUMN is_locked INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE programs ADD COLUMN is_finalized INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS lock_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  syllabus_id INTEGER,
+  action TEXT NOT NULL CHECK (action IN ('lock', 'unlock', 'finalize', 'unfinalize')),
+  actor TEXT NOT NULL DEFAULT '',
+  reason TEXT NOT NULL DEFAULT '',
+  created_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
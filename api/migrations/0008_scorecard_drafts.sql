@@ -0,0 +1,23 @@
+ALTER TABLE scorecards ADD COLUMN published_score REAL;
+ALTER TABLE scorecards ADD COLUMN is_published INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scorecards ADD COLUMN published_at INTEGER;
+ALTER TABLE scorecard_items ADD COLUMN published_score REAL;
+
+-- Publishing shouldn't change when the draft was generated
+DROP TRIGGER IF EXISTS generated_at;
+
+CREATE TRIGGER IF NOT EXISTS generated_at
+AFTER UPDATE OF score, is_outdated ON scorecards
+FOR EACH ROW
+BEGIN
+  UPDATE scorecards
+  SET generated_at = CURRENT_TIMESTAMP
+  WHERE id = OLD.id;
+END;
+
+-- Scorecards generated before drafts existed were already the official ones
+UPDATE scorecards
+SET published_score = score, is_published = TRUE, published_at = generated_at;
+
+UPDATE scorecard_items
+SET published_score = score;
@@ -0,0 +1 @@
+ALTER TABLE scorecard_structures ADD COLUMN is_linked INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,11 @@
+ALTER TABLE scorecard_structures ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+-- Keeps the current order, which was the order the structures were inserted in
+UPDATE scorecard_structures
+SET position = (
+  SELECT COUNT(ss.id)
+  FROM scorecard_structures ss
+  WHERE ss.program_id = scorecard_structures.program_id
+    AND ss.parent_id IS scorecard_structures.parent_id
+    AND ss.rowid < scorecard_structures.rowid
+);
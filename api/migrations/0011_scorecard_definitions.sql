@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS scorecard_definitions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (program_id, title),
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER IF NOT EXISTS scorecard_definitions_updated_at
+AFTER UPDATE OF program_id, title ON scorecard_definitions
+FOR EACH ROW
+BEGIN
+  UPDATE scorecard_definitions
+  SET updated_at = CURRENT_TIMESTAMP
+  WHERE id = OLD.id;
+END;
+
+-- The existing structures and scorecards become the first definition of their program
+INSERT INTO scorecard_definitions (program_id, title)
+SELECT id, 'Scorecard'
+FROM programs;
+
+ALTER TABLE scorecard_structures ADD COLUMN definition_id INTEGER REFERENCES scorecard_definitions(id) ON DELETE CASCADE;
+
+UPDATE scorecard_structures
+SET definition_id = (
+  SELECT id
+  FROM scorecard_definitions
+  WHERE program_id = scorecard_structures.program_id
+);
+
+-- A user has a scorecard per definition instead of per program, which changes the unique constraint, so the table has
+-- to be rebuilt
+PRAGMA foreign_keys = OFF;
+
+CREATE TABLE scorecards_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  definition_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  score REAL NOT NULL,
+  is_outdated INTEGER NOT NULL DEFAULT 0,
+  generated_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  published_score REAL,
+  is_published INTEGER NOT NULL DEFAULT 0,
+  published_at INTEGER,
+  UNIQUE (definition_id, user_id),
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE,
+  FOREIGN KEY (definition_id) REFERENCES scorecard_definitions(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO scorecards_new (id, program_id, definition_id, user_id, score, is_outdated, generated_at, published_score, is_published, published_at)
+SELECT s.id, s.program_id, d.id, s.user_id, s.score, s.is_outdated, s.generated_at, s.published_score, s.is_published, s.published_at
+FROM scorecards s
+JOIN scorecard_definitions d ON d.program_id = s.program_id;
+
+DROP TABLE scorecards;
+ALTER TABLE scorecards_new RENAME TO scorecards;
+
+CREATE TRIGGER IF NOT EXISTS generated_at
+AFTER UPDATE OF score, is_outdated ON scorecards
+FOR EACH ROW
+BEGIN
+  UPDATE scorecards
+  SET generated_at = CURRENT_TIMESTAMP
+  WHERE id = OLD.id;
+END;
+
+PRAGMA foreign_keys = ON;
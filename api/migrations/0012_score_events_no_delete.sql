@@ -0,0 +1,9 @@
+-- A score event can't be deleted on its own. The events of a deleted program are still removed by the cascade, as the
+-- program is already gone when its events are deleted.
+CREATE TRIGGER IF NOT EXISTS score_events_no_delete
+BEFORE DELETE ON score_events
+FOR EACH ROW
+WHEN EXISTS (SELECT id FROM programs WHERE id = OLD.program_id)
+BEGIN
+  SELECT RAISE(ABORT, 'score_events is append-only');
+END;
@@ -0,0 +1,12 @@
+-- The feedback is part of the published snapshot, so editing it doesn't change what the user already sees
+ALTER TABLE scorecard_items ADD COLUMN published_feedback TEXT;
+
+UPDATE scorecard_items
+SET published_feedback = (
+  SELECT us.feedback
+  FROM scorecards s
+  JOIN scorecard_structures ss ON ss.id = scorecard_items.structure_id
+  JOIN user_scores us ON us.user_id = s.user_id AND us.syllabus_id = ss.syllabus_id
+  WHERE s.id = scorecard_items.scorecard_id
+)
+WHERE published_score IS NOT NULL;
@@ -0,0 +1,2 @@
+-- The score of a parent is the mean of its children weighted by this, every structure counts the same by default
+ALTER TABLE scorecard_structures ADD COLUMN weight REAL NOT NULL DEFAULT 1 CHECK (weight > 0);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS grade_scales (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  program_id INTEGER NOT NULL,
+  title TEXT NOT NULL,
+  grades TEXT NOT NULL DEFAULT '[]',
+  created_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at INTEGER NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (program_id, title),
+  FOREIGN KEY (program_id) REFERENCES programs(id) ON DELETE CASCADE
+);
+
+CREATE TRIGGER IF NOT EXISTS grade_scales_updated_at
+AFTER UPDATE OF program_id, title, grades ON grade_scales
+FOR EACH ROW
+BEGIN
+  UPDATE grade_scales
+  SET updated_at = CURRENT_TIMESTAMP
+  WHERE id = OLD.id;
+END;
+
+ALTER TABLE scorecard_definitions ADD COLUMN grade_scale_id INTEGER REFERENCES grade_scales(id) ON DELETE SET NULL;
@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+// newDB applies every migration in order to an empty database, the same way the Makefile does
+func newDB(t *testing.T) *sqlx.DB {
+	db := sqlx.MustOpen("sqlite3", ":memory:?_foreign_keys=on")
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	files, _ := filepath.Glob("*.sql")
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(b)); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	return db
+}
+
+func TestScoreEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newDB(t)
+	db.MustExec(`INSERT INTO programs (id, title) VALUES (1, 'Program 1')`)
+	db.MustExec(`INSERT INTO score_events (id, program_id, user_id, syllabus_id, new_score) VALUES (1, 1, 1, 1, 100)`)
+
+	_, err := db.Exec(`UPDATE score_events SET new_score = 90 WHERE id = 1`)
+	assert.ErrorContains(err, "score_events is append-only")
+
+	_, err = db.Exec(`DELETE FROM score_events WHERE id = 1`)
+	assert.ErrorContains(err, "score_events is append-only")
+
+	// The events are removed along with their program
+	db.MustExec(`DELETE FROM programs WHERE id = 1`)
+	var count int
+	assert.Nil(db.Get(&count, `SELECT COUNT(id) FROM score_events`))
+	assert.Equal(0, count)
+}
+
+func TestGradeScales(t *testing.T) {
+	assert := assert.New(t)
+
+	db := newDB(t)
+	db.MustExec(`INSERT INTO programs (id, title) VALUES (1, 'Program 1')`)
+	db.MustExec(`INSERT INTO grade_scales (id, program_id, title) VALUES (1, 1, 'Letter')`)
+	db.MustExec(`INSERT INTO scorecard_definitions (id, program_id, title, grade_scale_id) VALUES (1, 1, 'Scorecard', 1)`)
+
+	var grades string
+	assert.Nil(db.Get(&grades, `SELECT grades FROM grade_scales WHERE id = 1`))
+	assert.Equal("[]", grades)
+
+	// The definitions are left without a grade scale once it's deleted
+	db.MustExec(`DELETE FROM grade_scales WHERE id = 1`)
+	var gradeScaleID *int
+	assert.Nil(db.Get(&gradeScaleID, `SELECT grade_scale_id FROM scorecard_definitions WHERE id = 1`))
+	assert.Nil(gradeScaleID)
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type GradeScale struct {
+	ID     int    `json:"id"`
+	Title  string `json:"title"`
+	Grades Grades `json:"grades"`
+}
+
+type Grade struct {
+	Grade    string  `json:"grade"`
+	MinScore float64 `json:"minScore"`
+}
+
+// Grades is stored as a JSON array
+type Grades []*Grade
+
+// Grade returns the grade with the highest minimum score that the score reaches, or an empty string if it doesn't reach
+// any of them
+func (g Grades) Grade(score float64) string {
+	var grade *Grade
+	for _, v := range g {
+		if score >= v.MinScore && (grade == nil || v.MinScore > grade.MinScore) {
+			grade = v
+		}
+	}
+	if grade == nil {
+		return ""
+	}
+	return grade.Grade
+}
+
+// Validate checks that every grade has a name and a non-negative minimum score, and that neither the names nor the
+// minimum scores are repeated
+func (g Grades) Validate() error {
+	names := make(map[string]bool)
+	minScores := make(map[float64]bool)
+	for _, v := range g {
+		switch {
+		case v == nil || v.Grade == "":
+			return fmt.Errorf("a grade is missing its name")
+		case v.MinScore < 0:
+			return fmt.Errorf("the minimum score of %q should not be negative", v.Grade)
+		case names[v.Grade]:
+			return fmt.Errorf("%q is used more than once", v.Grade)
+		case minScores[v.MinScore]:
+			return fmt.Errorf("%v is the minimum score of more than one grade", v.MinScore)
+		}
+		names[v.Grade] = true
+		minScores[v.MinScore] = true
+	}
+	return nil
+}
+
+func (g *Grades) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), g)
+	case []byte:
+		return json.Unmarshal(v, g)
+	case nil:
+		*g = Grades{}
+		return nil
+	}
+	return fmt.Errorf("unsupported grades format: %T", value)
+}
+
+func (g Grades) Value() (driver.Value, error) {
+	b, err := g.MarshalJSON()
+	return string(b), err
+}
+
+func (g Grades) MarshalJSON() ([]byte, error) {
+	if g == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]*Grade(g))
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrades_Grade(t *testing.T) {
+	assert := assert.New(t)
+
+	grades := Grades{{"B", 80}, {"A", 90}, {"C", 60}}
+	assert.Equal("A", grades.Grade(95))
+	assert.Equal("A", grades.Grade(90))
+	assert.Equal("B", grades.Grade(89.9))
+	assert.Equal("C", grades.Grade(60))
+	assert.Equal("", grades.Grade(59))
+	assert.Equal("", Grades{}.Grade(100))
+}
+
+func TestGrades_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		grades Grades
+		ok     bool
+	}{
+		{"empty", Grades{}, true},
+		{"valid", Grades{{"A", 90}, {"B", 80}, {"C", 0}}, true},
+		{"nil grade", Grades{nil}, false},
+		{"empty grade", Grades{{"", 90}}, false},
+		{"negative min score", Grades{{"A", -1}}, false},
+		{"duplicate grade", Grades{{"A", 90}, {"A", 80}}, false},
+		{"duplicate min score", Grades{{"A", 90}, {"B", 90}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.grades.Validate()
+			if tt.ok {
+				assert.Nil(t, err)
+			} else {
+				assert.NotNil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package model
+
+const (
+	LockActionLock       = "lock"
+	LockActionUnlock     = "unlock"
+	LockActionFinalize   = "finalize"
+	LockActionUnfinalize = "unfinalize"
+)
+
+type LockEvent struct {
+	ID         int    `json:"id"`
+	SyllabusID *int   `json:"syllabusId" db:"syllabus_id"`
+	Action     string `json:"action"`
+	Actor      string `json:"actor"`
+	Reason     string `json:"reason"`
+	CreatedAt  Time   `json:"createdAt" db:"created_at"`
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"database/sql/driver"
 	"fmt"
 	"time"
 )
@@ -28,3 +29,7 @@ func (t *Time) Scan(value interface{}) error {
 	}
 	return fmt.Errorf("unsupported time format: %T", value)
 }
+
+func (t Time) Value() (driver.Value, error) {
+	return t.UTC().Format("2006-01-02 15:04:05"), nil
+}
@@ -1,6 +1,7 @@
 package model
 
 type Program struct {
-	ID    int    `json:"id"`
-	Title string `json:"title"`
+	ID          int    `json:"id"`
+	Title       string `json:"title"`
+	IsFinalized bool   `json:"isFinalized" db:"is_finalized"`
 }
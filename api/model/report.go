@@ -0,0 +1,9 @@
+package model
+
+type ReportTemplate struct {
+	Header   string  `json:"header"`
+	Footer   string  `json:"footer"`
+	Logo     []byte  `json:"-"`
+	LogoType *string `json:"-" db:"logo_type"`
+	HasLogo  bool    `json:"hasLogo" db:"-"`
+}
@@ -0,0 +1,12 @@
+package model
+
+type ScoreEvent struct {
+	ID         int      `json:"id"`
+	UserID     int      `json:"userId" db:"user_id"`
+	SyllabusID int      `json:"syllabusId" db:"syllabus_id"`
+	OldScore   *float64 `json:"oldScore" db:"old_score"`
+	NewScore   float64  `json:"newScore" db:"new_score"`
+	Actor      string   `json:"actor"`
+	Reason     string   `json:"reason"`
+	CreatedAt  Time     `json:"createdAt" db:"created_at"`
+}
@@ -1,30 +1,73 @@
 package model
 
+// DefaultScorecardDefinitionTitle is the title of the definition that every program is created with
+const DefaultScorecardDefinitionTitle = "Scorecard"
+
+type ScorecardDefinition struct {
+	ID           int    `json:"id"`
+	Title        string `json:"title"`
+	GradeScaleID *int   `json:"gradeScaleId" db:"grade_scale_id"`
+}
+
 type ScorecardStructure struct {
-	ID         int                         `json:"id"`
-	ParentID   *int                        `json:"parentId" db:"parent_id"`
-	Title      string                      `json:"title"`
-	SyllabusID *int                        `json:"-" db:"syllabus_id"`
-	Syllabus   *ScorecardStructureSyllabus `json:"syllabus" db:"-"`
+	ID           int                         `json:"id"`
+	DefinitionID int                         `json:"-" db:"definition_id"`
+	ParentID     *int                        `json:"parentId" db:"parent_id"`
+	Title        string                      `json:"title"`
+	SyllabusID   *int                        `json:"-" db:"syllabus_id"`
+	Syllabus     *ScorecardStructureSyllabus `json:"syllabus" db:"-"`
+	Position     int                         `json:"position"`
+	Weight       float64                     `json:"weight"`
+	IsLinked     bool                        `json:"isLinked" db:"is_linked"`
 }
 
 type ScorecardStructureSyllabus struct {
 	BaseSyllabus
-	IsAssignment bool `json:"isAssignment" db:"is_assignment"`
+	Description  string  `json:"description"`
+	Code         *string `json:"code"`
+	IsAssignment bool    `json:"isAssignment" db:"is_assignment"`
 }
 
 type Scorecard struct {
-	ID          int              `json:"id"`
-	UserID      int              `json:"-" db:"user_id"`
-	User        *User            `json:"user" db:"-"`
-	Score       float64          `json:"score"`
-	Items       []*ScorecardItem `json:"items"`
-	IsOutdated  bool             `json:"isOutdated" db:"is_outdated"`
-	IsInQueue   bool             `json:"isInQueue"`
-	GeneratedAt Time             `json:"generatedAt" db:"generated_at"`
+	ID             int              `json:"id"`
+	UserID         int              `json:"-" db:"user_id"`
+	User           *User            `json:"user" db:"-"`
+	Score          float64          `json:"score"`
+	PublishedScore *float64         `json:"publishedScore" db:"published_score"`
+	Items          []*ScorecardItem `json:"items"`
+	IsOutdated     bool             `json:"isOutdated" db:"is_outdated"`
+	IsPublished    bool             `json:"isPublished" db:"is_published"`
+	IsInQueue      bool             `json:"isInQueue"`
+	GeneratedAt    Time             `json:"generatedAt" db:"generated_at"`
+	PublishedAt    *Time            `json:"publishedAt" db:"published_at"`
 }
 
 type ScorecardItem struct {
-	StructureID int     `json:"structureId" db:"structure_id"`
-	Score       float64 `json:"score"`
+	StructureID    int      `json:"structureId" db:"structure_id"`
+	Score          float64  `json:"score"`
+	PublishedScore *float64 `json:"publishedScore" db:"published_score"`
+	Feedback       *string  `json:"feedback"`
+}
+
+type ScorecardSchedule struct {
+	ID         int    `json:"id"`
+	Expression string `json:"expression"`
+	IsEnabled  bool   `json:"isEnabled" db:"is_enabled"`
+	LastRunAt  *Time  `json:"lastRunAt" db:"last_run_at"`
+	NextRunAt  *Time  `json:"nextRunAt" db:"-"`
+}
+
+const (
+	FindingSeverityError   = "error"
+	FindingSeverityWarning = "warning"
+	FindingSeverityInfo    = "info"
+)
+
+type ScorecardStructureFinding struct {
+	Code        string `json:"code"`
+	Severity    string `json:"severity"`
+	StructureID *int   `json:"structureId"`
+	SyllabusID  *int   `json:"syllabusId"`
+	Message     string `json:"message"`
+	Suggestion  string `json:"suggestion"`
 }
@@ -1,5 +1,11 @@
 package model
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
 type SyllabusStructure struct {
 	ID     int    `json:"id"`
 	PrevID *int   `json:"prevId" db:"prev_id"`
@@ -11,8 +17,53 @@ type BaseSyllabus struct {
 	Title string `json:"title"`
 }
 
+const (
+	SyllabusVisibilityVisible = "visible"
+	SyllabusVisibilityHidden  = "hidden"
+)
+
+type SyllabusMetadata struct {
+	Description string   `json:"description"`
+	Code        *string  `json:"code"`
+	Position    int      `json:"position"`
+	Tags        Tags     `json:"tags"`
+	MaxScore    *float64 `json:"maxScore" db:"max_score"`
+	DueAt       *Time    `json:"dueAt" db:"due_at"`
+	Visibility  string   `json:"visibility"`
+}
+
 type Syllabus struct {
 	BaseSyllabus
 	ParentID    *int `json:"parentId" db:"parent_id"`
 	StructureID *int `json:"structureId" db:"structure_id"`
+	SyllabusMetadata
+	IsLocked bool `json:"isLocked" db:"is_locked"`
+}
+
+// Tags is stored as a JSON array
+type Tags []string
+
+func (t *Tags) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case []byte:
+		return json.Unmarshal(v, t)
+	case nil:
+		*t = Tags{}
+		return nil
+	}
+	return fmt.Errorf("unsupported tags format: %T", value)
+}
+
+func (t Tags) Value() (driver.Value, error) {
+	b, err := t.MarshalJSON()
+	return string(b), err
+}
+
+func (t Tags) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]string(t))
 }
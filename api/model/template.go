@@ -0,0 +1,11 @@
+package model
+
+import "encoding/json"
+
+type Template struct {
+	ID        int             `json:"id"`
+	Name      string          `json:"name"`
+	Version   int             `json:"version"`
+	Document  json.RawMessage `json:"document,omitempty" db:"-"`
+	CreatedAt Time            `json:"createdAt" db:"created_at"`
+}
@@ -0,0 +1,196 @@
+// Package report renders scorecards as printable PDF report cards
+package report
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+)
+
+var ErrUnsupportedLogoType = errors.New("report: unsupported logo type")
+
+// Template customizes every page of a report card. Header and Footer are plain text, Logo is a PNG or JPG image drawn
+// next to the header.
+type Template struct {
+	Header   string
+	Footer   string
+	Logo     []byte
+	LogoType string
+}
+
+// Card is a scorecard of a single user. Items are ordered the same way as the scorecard structures, so an item is
+// always placed right after its parent. The grade column is only rendered when IsGraded is true.
+type Card struct {
+	ProgramTitle string
+	UserName     string
+	Score        float64
+	IsOutdated   bool
+	PublishedAt  time.Time
+	IsGraded     bool
+	Grade        string
+	Items        []*Item
+}
+
+type Item struct {
+	Title string
+	Depth int
+	// Score is nil when the user doesn't have a score for the structure
+	Score *float64
+	// Grade is empty when the score doesn't reach any grade
+	Grade string
+}
+
+// LogoType returns the image type of a logo based on its filename
+func LogoType(filename string) (string, error) {
+	switch {
+	case strings.HasSuffix(strings.ToLower(filename), ".png"):
+		return "png", nil
+	case strings.HasSuffix(strings.ToLower(filename), ".jpg"), strings.HasSuffix(strings.ToLower(filename), ".jpeg"):
+		return "jpg", nil
+	}
+	return "", ErrUnsupportedLogoType
+}
+
+// CheckLogo returns ErrUnsupportedLogoType unless the logo is an image of the given type, so a file with the wrong
+// extension is rejected before it breaks every report card
+func CheckLogo(logo []byte, typ string) error {
+	_, format, err := image.DecodeConfig(bytes.NewReader(logo))
+	if format == "jpeg" {
+		format = "jpg"
+	}
+	if err != nil || format != typ {
+		return ErrUnsupportedLogoType
+	}
+	return nil
+}
+
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// Render writes the card as a PDF document into w
+func Render(w io.Writer, card *Card, tmpl *Template) error {
+	if tmpl == nil {
+		tmpl = &Template{}
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(card.ProgramTitle+" - "+card.UserName, true)
+	pdf.AliasNbPages("")
+	tr := pdf.UnicodeTranslatorFromDescriptor("") // the core fonts only support cp1252
+
+	left, _, right, _ := pdf.GetMargins()
+	width, _ := pdf.GetPageSize()
+	width -= left + right
+
+	if len(tmpl.Logo) > 0 {
+		pdf.RegisterImageOptionsReader("logo", fpdf.ImageOptions{ImageType: tmpl.LogoType}, bytes.NewReader(tmpl.Logo))
+		if pdf.Err() {
+			return pdf.Error()
+		}
+	}
+
+	pdf.SetHeaderFunc(func() {
+		x := left
+		if len(tmpl.Logo) > 0 {
+			pdf.ImageOptions("logo", left, 8, 0, 12, false, fpdf.ImageOptions{ImageType: tmpl.LogoType}, 0, "")
+			x += 30
+		}
+		if tmpl.Header != "" {
+			pdf.SetFont("Helvetica", "", 10)
+			pdf.SetTextColor(96, 96, 96)
+			pdf.SetXY(x, 10)
+			pdf.CellFormat(width-(x-left), 8, tr(tmpl.Header), "", 0, "R", false, 0, "")
+		}
+		pdf.SetY(25)
+	})
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(96, 96, 96)
+		pdf.CellFormat(width-20, 10, tr(tmpl.Footer), "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 10, strconv.Itoa(pdf.PageNo())+"/{nb}", "", 0, "R", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(width, 10, tr(card.ProgramTitle), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.CellFormat(width, 8, tr(card.UserName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.SetTextColor(96, 96, 96)
+	publishedAt := "Published at " + card.PublishedAt.Format(time.DateTime)
+	if card.IsOutdated {
+		publishedAt += " (outdated)"
+	}
+	pdf.CellFormat(width, 6, publishedAt, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	gradeWidth := 0.0
+	if card.IsGraded {
+		gradeWidth = 20
+	}
+	titleWidth := width - 30 - gradeWidth
+
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(titleWidth, 8, "Structure", "B", 0, "L", true, 0, "")
+	if card.IsGraded {
+		pdf.CellFormat(30, 8, "Score", "B", 0, "R", true, 0, "")
+		pdf.CellFormat(gradeWidth, 8, "Grade", "B", 1, "R", true, 0, "")
+	} else {
+		pdf.CellFormat(30, 8, "Score", "B", 1, "R", true, 0, "")
+	}
+
+	for _, item := range card.Items {
+		style := ""
+		if item.Depth == 0 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+
+		indent := float64(item.Depth) * 5
+		score, grade := "-", "-"
+		if item.Score != nil {
+			score = formatScore(*item.Score)
+			if item.Grade != "" {
+				grade = item.Grade
+			}
+		}
+		pdf.SetX(left + indent)
+		pdf.CellFormat(titleWidth-indent, 7, tr(item.Title), "", 0, "L", false, 0, "")
+		if card.IsGraded {
+			pdf.CellFormat(30, 7, score, "", 0, "R", false, 0, "")
+			pdf.CellFormat(gradeWidth, 7, tr(grade), "", 1, "R", false, 0, "")
+		} else {
+			pdf.CellFormat(30, 7, score, "", 1, "R", false, 0, "")
+		}
+	}
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(titleWidth, 9, "Total", "T", 0, "L", false, 0, "")
+	if card.IsGraded {
+		grade := card.Grade
+		if grade == "" {
+			grade = "-"
+		}
+		pdf.CellFormat(30, 9, formatScore(card.Score), "T", 0, "R", false, 0, "")
+		pdf.CellFormat(gradeWidth, 9, tr(grade), "T", 1, "R", false, 0, "")
+	} else {
+		pdf.CellFormat(30, 9, formatScore(card.Score), "T", 1, "R", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
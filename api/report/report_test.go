@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogoType(t *testing.T) {
+	assert := assert.New(t)
+
+	typ, err := LogoType("logo.PNG")
+	assert.Nil(err)
+	assert.Equal("png", typ)
+
+	typ, err = LogoType("logo.jpeg")
+	assert.Nil(err)
+	assert.Equal("jpg", typ)
+
+	_, err = LogoType("logo.svg")
+	assert.Equal(ErrUnsupportedLogoType, err)
+}
+
+func TestCheckLogo(t *testing.T) {
+	assert := assert.New(t)
+
+	var logo bytes.Buffer
+	png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+	assert.Nil(CheckLogo(logo.Bytes(), "png"))
+	assert.Equal(ErrUnsupportedLogoType, CheckLogo(logo.Bytes(), "jpg"))
+	assert.Equal(ErrUnsupportedLogoType, CheckLogo([]byte("logo"), "png"))
+}
+
+func TestRender(t *testing.T) {
+	assert := assert.New(t)
+
+	score := 90.0
+	card := &Card{
+		ProgramTitle: "Program 1",
+		UserName:     "User 1",
+		Score:        score,
+		IsOutdated:   true,
+		PublishedAt:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Items: []*Item{
+			{Title: "Syllabus 1", Depth: 0, Score: &score},
+			{Title: "Assignment 1", Depth: 1, Score: &score},
+			{Title: "Assignment 2", Depth: 1},
+		},
+	}
+
+	t.Run("without template", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(Render(&buf, card, nil))
+		assert.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("with template", func(t *testing.T) {
+		var logo bytes.Buffer
+		png.Encode(&logo, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+		var buf bytes.Buffer
+		assert.Nil(Render(&buf, card, &Template{Header: "Header", Footer: "Footer", Logo: logo.Bytes(), LogoType: "png"}))
+		assert.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("graded", func(t *testing.T) {
+		card := *card
+		card.IsGraded = true
+		card.Grade = "A"
+		card.Items = []*Item{
+			{Title: "Syllabus 1", Depth: 0, Score: &score, Grade: "A"},
+			{Title: "Assignment 1", Depth: 1, Score: &score},
+		}
+
+		var buf bytes.Buffer
+		assert.Nil(Render(&buf, &card, nil))
+		assert.True(bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+	})
+
+	t.Run("invalid logo", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NotNil(Render(&buf, card, &Template{Logo: []byte("logo"), LogoType: "png"}))
+	})
+}
@@ -8,48 +8,122 @@ import (
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/go-redis/redis/v8"
 	"github.com/jmoiron/sqlx"
 	"github.com/rs/zerolog/log"
 	"github.com/vmihailenco/taskq/v3"
 	"github.com/vmihailenco/taskq/v3/memqueue"
+	"github.com/vmihailenco/taskq/v3/redisq"
 )
 
 type GeneratorInterface interface {
 	Start()
 	Stats() *GeneratorStats
 	IsInQueue(scorecardID int) bool
-	Enqueue(ctx context.Context, programID, userID, scorecardID int)
+	InQueue(scorecardIds []int) map[int]bool
+	Enqueue(ctx context.Context, programID, definitionID, userID, scorecardID int, priority Priority) EnqueueStatus
+	Cancel(programID int)
+}
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityHigh
+)
+
+type EnqueueStatus string
+
+const (
+	EnqueueStatusQueued  EnqueueStatus = "queued"
+	EnqueueStatusMerged  EnqueueStatus = "merged"
+	EnqueueStatusSkipped EnqueueStatus = "skipped"
+)
+
+type job struct {
+	ProgramID    int
+	DefinitionID int
+	UserID       int
+	ScorecardID  int
+	Priority     Priority
+
+	key string // identifies a running job in redisStore
 }
 
 type Generator struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	redis *redis.Client
 
 	queue taskq.Queue
 	task  *taskq.Task
 
-	scorecardIds map[int]bool
+	// The queue only carries signals, the jobs themselves are kept in the store so they can be deduplicated,
+	// prioritized and cancelled. Every queued job adds exactly one message, so there are never fewer messages than
+	// pending jobs.
+	store store
+
+	mu      sync.Mutex
+	cancels map[*job]context.CancelFunc
 }
 
+// NewGenerator uses an in-memory queue unless GENERATOR_QUEUE is set to redis, in which case the queue and its
+// bookkeeping are stored in REDIS_URL and shared by every instance.
 func NewGenerator(db *sqlx.DB) GeneratorInterface {
-	return &Generator{
+	g := &Generator{
 		db: db,
 
-		scorecardIds: make(map[int]bool),
+		store:   newMemoryStore(),
+		cancels: make(map[*job]context.CancelFunc),
 	}
+
+	if os.Getenv("GENERATOR_QUEUE") == "redis" {
+		opt, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("scorecard.NewGenerator")
+		}
+		g.redis = redis.NewClient(opt)
+		g.store = newRedisStore(g.redis)
+	}
+
+	return g
 }
 
 func (g *Generator) Start() {
-	factory := memqueue.NewFactory()
-
-	g.queue = factory.RegisterQueue(&taskq.QueueOptions{
+	opt := &taskq.QueueOptions{
 		Name:         "scorecard",
 		MaxNumWorker: 1,
-	})
+	}
 
 	g.task = taskq.RegisterTask(&taskq.TaskOptions{
 		Name:    "generate",
-		Handler: g.generate,
+		Handler: g.process,
 	})
+
+	if g.redis == nil {
+		g.queue = memqueue.NewFactory().RegisterQueue(opt)
+		return
+	}
+
+	opt.Redis = g.redis
+	factory := redisq.NewFactory()
+	g.queue = factory.RegisterQueue(opt)
+	if err := factory.StartConsumers(context.Background()); err != nil {
+		log.Fatal().Err(err).Msg("scorecard.Generator.Start")
+	}
+
+	go g.subscribe(context.Background())
+}
+
+// subscribe cancels the jobs running on this instance when another instance cancels their program
+func (g *Generator) subscribe(ctx context.Context) {
+	pubsub := g.redis.Subscribe(ctx, redisCancelKey)
+	defer pubsub.Close()
+
+	for msg := range pubsub.Channel() {
+		if programID, err := strconv.Atoi(msg.Payload); err == nil {
+			g.cancelRunning(programID)
+		}
+	}
 }
 
 type GeneratorStats struct {
@@ -57,41 +131,212 @@ type GeneratorStats struct {
 }
 
 func (g *Generator) Stats() *GeneratorStats {
-	stats := g.queue.Consumer().Stats()
-	return &GeneratorStats{
-		InQueue: stats.Buffered + stats.InFlight,
+	stats, err := g.store.Stats(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.Stats")
+		return &GeneratorStats{}
 	}
+	return stats
 }
 
 func (g *Generator) IsInQueue(scorecardID int) bool {
-	_, ok := g.scorecardIds[scorecardID]
-	return ok
+	m, err := g.store.InQueue(context.Background(), []int{scorecardID})
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.IsInQueue")
+	}
+	return m[scorecardID]
 }
 
-func (g *Generator) Enqueue(ctx context.Context, programID, userID, scorecardID int) {
-	msg := g.task.WithArgs(ctx, programID, userID, scorecardID)
-	if err := g.queue.Add(msg); err != nil {
+// InQueue returns which of the scorecards have a pending or running job
+func (g *Generator) InQueue(scorecardIds []int) map[int]bool {
+	m, err := g.store.InQueue(context.Background(), scorecardIds)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.InQueue")
+		return map[int]bool{}
+	}
+	return m
+}
+
+func (g *Generator) Enqueue(ctx context.Context, programID, definitionID, userID, scorecardID int, priority Priority) EnqueueStatus {
+	j := &job{
+		ProgramID:    programID,
+		DefinitionID: definitionID,
+		UserID:       userID,
+		ScorecardID:  scorecardID,
+		Priority:     priority,
+	}
+
+	status, err := g.store.Add(ctx, j)
+	if err != nil {
 		log.Error().Err(err).Msg("scorecard.Generator.Enqueue")
+		return EnqueueStatusSkipped
+	}
+	if status != EnqueueStatusQueued {
+		return status
 	}
 
-	if scorecardID != 0 {
-		g.scorecardIds[scorecardID] = true
+	if err := g.queue.Add(g.task.WithArgs(ctx)); err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.Enqueue")
+		if err := g.store.Remove(ctx, j); err != nil {
+			log.Error().Err(err).Msg("scorecard.Generator.Enqueue")
+		}
+		return EnqueueStatusSkipped
 	}
+
+	return EnqueueStatusQueued
 }
 
-func (g *Generator) generate(programID, userID, scorecardID int) error {
-	if v, err := strconv.Atoi(os.Getenv("GENERATOR_DELAY")); err == nil {
-		time.Sleep(time.Duration(v) * time.Millisecond)
+// EnqueueProgram enqueues a generation of every definition of the program, or only of the given definition, for every
+// user that has at least one score in the program. The returned status is queued if at least one job was queued, merged
+// if every job was merged and skipped if there was nothing to enqueue.
+func EnqueueProgram(ctx context.Context, db *sqlx.DB, generator GeneratorInterface, programID, definitionID int) (EnqueueStatus, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT d.id AS definition_id, us.user_id, COALESCE(s2.id, 0) AS scorecard_id
+		FROM scorecard_definitions d
+		JOIN syllabus_structures ss ON ss.program_id = d.program_id
+		JOIN syllabuses s ON s.structure_id = ss.id
+		JOIN user_scores us ON us.syllabus_id = s.id
+		LEFT JOIN scorecards s2 ON s2.definition_id = d.id AND s2.user_id = us.user_id
+		WHERE d.program_id = ?
+		  AND (? = 0 OR d.id = ?)
+	`, programID, definitionID, definitionID)
+	if err != nil {
+		return EnqueueStatusSkipped, err
+	}
+	defer rows.Close()
+
+	status := EnqueueStatusSkipped
+	for rows.Next() {
+		var definitionID, userID, scorecardID int
+		if err := rows.Scan(&definitionID, &userID, &scorecardID); err != nil {
+			return status, err
+		}
+
+		switch generator.Enqueue(ctx, programID, definitionID, userID, scorecardID, PriorityLow) {
+		case EnqueueStatusQueued:
+			status = EnqueueStatusQueued
+		case EnqueueStatusMerged:
+			if status == EnqueueStatusSkipped {
+				status = EnqueueStatusMerged
+			}
+		}
+	}
+
+	return status, rows.Err()
+}
+
+// Cancel drops every pending job of the program along with its queued messages and stops the ones that are currently
+// running.
+func (g *Generator) Cancel(programID int) {
+	if err := g.store.Cancel(context.Background(), programID); err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.Cancel")
+	}
+	if err := g.purge(context.Background()); err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.Cancel")
+	}
+
+	if g.redis != nil {
+		if err := g.redis.Publish(context.Background(), redisCancelKey, programID).Err(); err != nil {
+			log.Error().Err(err).Msg("scorecard.Generator.Cancel")
+		}
+	}
+	g.cancelRunning(programID)
+}
+
+// purge removes every queued message and adds one back for each job that is still pending, as the messages are only
+// signals and can't be told apart. A job that is added in the meantime is either counted here or adds its message after
+// the purge, so there are still never fewer messages than pending jobs.
+func (g *Generator) purge(ctx context.Context) error {
+	if err := g.queue.Purge(); err != nil {
+		return err
+	}
+
+	n, err := g.store.Len(ctx)
+	if err != nil {
+		return err
 	}
+	for i := 0; i < n; i++ {
+		if err := g.queue.Add(g.task.WithArgs(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) cancelRunning(programID int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for j, cancel := range g.cancels {
+		if j.ProgramID == programID {
+			cancel()
+		}
+	}
+}
+
+// process runs the job with the highest priority
+func (g *Generator) process(ctx context.Context) error {
+	j, err := g.store.Pop(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.process")
+		return nil
+	}
+	if j == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	g.mu.Lock()
+	g.cancels[j] = cancel
+	g.mu.Unlock()
+
+	go g.refresh(ctx, j)
+
+	defer func() {
+		cancel()
+
+		g.mu.Lock()
+		delete(g.cancels, j)
+		g.mu.Unlock()
+
+		if err := g.store.Done(context.Background(), j); err != nil {
+			log.Error().Err(err).Msg("scorecard.Generator.process")
+		}
+	}()
+
+	return g.generate(ctx, j)
+}
+
+// refresh keeps the lease of the running job alive until ctx is done
+func (g *Generator) refresh(ctx context.Context, j *job) {
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := g.store.Refresh(ctx, j); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("scorecard.Generator.refresh")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
 
-	type ScorecardStructure struct {
-		ID         int
-		ParentID   *int `db:"parent_id"`
-		SyllabusID *int `db:"syllabus_id"`
+func (g *Generator) generate(ctx context.Context, j *job) error {
+	programID, definitionID, userID, scorecardID := j.ProgramID, j.DefinitionID, j.UserID, j.ScorecardID
+
+	if v, err := strconv.Atoi(os.Getenv("GENERATOR_DELAY")); err == nil {
+		select {
+		case <-time.After(time.Duration(v) * time.Millisecond):
+		case <-ctx.Done():
+			return nil
+		}
 	}
 
-	var rootIds []int
-	var structures []*ScorecardStructure
+	var structures []*Structure
 	assignments := make(map[int]float64)
 
 	var wg sync.WaitGroup
@@ -100,11 +345,12 @@ func (g *Generator) generate(programID, userID, scorecardID int) error {
 	go func() {
 		defer wg.Done()
 
-		rows, err := g.db.Queryx(`
-			SELECT id, parent_id, syllabus_id
+		rows, err := g.db.QueryxContext(ctx, `
+			SELECT id, parent_id, syllabus_id, weight
 			FROM scorecard_structures
-			WHERE program_id = ?
-		`, programID)
+			WHERE definition_id = ?
+			ORDER BY position, rowid
+		`, definitionID)
 		if err != nil {
 			log.Error().Err(err).Msg("scorecard.Generator.generate")
 			return
@@ -112,14 +358,11 @@ func (g *Generator) generate(programID, userID, scorecardID int) error {
 		defer rows.Close()
 
 		for rows.Next() {
-			var node ScorecardStructure
+			var node Structure
 			if err := rows.StructScan(&node); err != nil {
 				log.Error().Err(err).Msg("scorecard.Generator.generate")
 				continue
 			}
-			if node.ParentID == nil {
-				rootIds = append(rootIds, node.ID)
-			}
 			structures = append(structures, &node)
 		}
 	}()
@@ -128,7 +371,7 @@ func (g *Generator) generate(programID, userID, scorecardID int) error {
 	go func() {
 		defer wg.Done()
 
-		rows, err := g.db.Query(`
+		rows, err := g.db.QueryContext(ctx, `
 			SELECT syllabus_id, score
 			FROM user_scores
 			WHERE user_id = ?
@@ -153,53 +396,33 @@ func (g *Generator) generate(programID, userID, scorecardID int) error {
 	wg.Wait()
 
 	if len(structures) == 0 || len(assignments) == 0 {
-		delete(g.scorecardIds, scorecardID)
 		return nil
 	}
 
-	nodes := make([]*Node, len(structures))
-	for i, structure := range structures {
-		var score float64
-		if structure.SyllabusID != nil {
-			score = assignments[*structure.SyllabusID]
-		}
+	score, nodes := Reduce(structures, assignments)
 
-		nodes[i] = &Node{
-			ID:       structure.ID,
-			ParentID: structure.ParentID,
-			Score:    score,
-		}
-	}
-
-	reducer := NewReducer()
-	reducer.SetNodes(nodes)
-	reducer.Reduce()
-
-	tx := g.db.MustBegin()
-
-	var score float64
-
-	roots := reducer.GetRoots()
-	for _, node := range roots {
-		score += node.Score
+	tx, err := g.db.BeginTxx(ctx, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Generator.generate")
+		return nil
 	}
-	score /= float64(len(roots))
 
 	if scorecardID == 0 {
-		err := tx.QueryRow(`
-			INSERT INTO scorecards (program_id, user_id, score)
-			VALUES (?, ?, ?)
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO scorecards (program_id, definition_id, user_id, score)
+			VALUES (?, ?, ?, ?)
 			RETURNING id
-		`, programID, userID, score).Scan(&scorecardID)
+		`, programID, definitionID, userID, score).Scan(&scorecardID)
 		if err != nil {
 			tx.Rollback()
 			log.Error().Err(err).Msg("scorecard.Generator.generate")
 			return nil
 		}
 	} else {
-		_, err := tx.Exec(`
+		// A new draft replaces the previous one, the published snapshot is kept until the draft is published
+		_, err := tx.ExecContext(ctx, `
 			UPDATE scorecards
-			SET score = ?, is_outdated = FALSE
+			SET score = ?, is_outdated = FALSE, is_published = FALSE
 			WHERE id = ?
 		`, score, scorecardID)
 		if err != nil {
@@ -209,22 +432,37 @@ func (g *Generator) generate(programID, userID, scorecardID int) error {
 		}
 	}
 
+	// Every node is kept, so the export and the report card can show the score of any node of the structure
 	qb := sq.Insert("scorecard_items").Columns("scorecard_id", "structure_id", "score").
 		Suffix("ON CONFLICT (scorecard_id, structure_id) DO UPDATE SET score = EXCLUDED.score")
-	for _, rootID := range rootIds {
-		for _, node := range reducer.GetByParentID(rootID) {
-			qb = qb.Values(scorecardID, node.ID, node.Score)
-		}
+	for _, node := range nodes {
+		qb = qb.Values(scorecardID, node.ID, node.Score)
 	}
 
-	if _, err := qb.RunWith(tx).Exec(); err != nil {
+	if _, err := qb.RunWith(tx).ExecContext(ctx); err != nil {
 		tx.Rollback()
 		log.Error().Err(err).Msg("scorecard.Generator.generate")
 		return nil
 	}
 
-	tx.Commit()
+	// The job might have been cancelled after the last statement, either here or on another instance whose cancel
+	// hasn't reached this one yet
+	if ctx.Err() != nil {
+		tx.Rollback()
+		return nil
+	}
+	if ok, err := g.store.IsCancelled(ctx, j); err != nil || ok {
+		tx.Rollback()
+		if err != nil {
+			log.Error().Err(err).Msg("scorecard.Generator.generate")
+		}
+		return nil
+	}
+
+	// A commit that fails, e.g. because the job was cancelled in the meantime, leaves the previous scorecard as it is
+	if err := tx.Commit(); err != nil && ctx.Err() == nil {
+		log.Error().Err(err).Msg("scorecard.Generator.generate")
+	}
 
-	delete(g.scorecardIds, scorecardID)
 	return nil
 }
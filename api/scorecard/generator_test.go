@@ -1,69 +1,125 @@
 package scorecard
 
 import (
+	"context"
+	"database/sql/driver"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/brantem/scorecard/testutil/db"
+	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/taskq/v3"
+	"github.com/vmihailenco/taskq/v3/memqueue"
 )
 
+// newTestGenerator returns a generator whose queue isn't consumed, so the messages stay in the queue
+func newTestGenerator(t *testing.T, db *sqlx.DB) *Generator {
+	g := NewGenerator(db).(*Generator)
+
+	g.task = taskq.RegisterTask(&taskq.TaskOptions{
+		Name:    t.Name(),
+		Handler: func() error { return nil },
+	})
+	t.Cleanup(func() { taskq.Tasks.Unregister(g.task) })
+
+	queue := memqueue.NewQueue(&taskq.QueueOptions{Name: t.Name(), BufferSize: 10})
+	queue.Consumer().Stop()
+	g.queue = queue
+
+	return g
+}
+
+// cancelArg matches any argument and cancels the program while the statement is running
+type cancelArg struct {
+	g         *Generator
+	programID int
+}
+
+func (a cancelArg) Match(driver.Value) bool {
+	a.g.Cancel(a.programID)
+	return true
+}
+
+func TestGenerator_Cancel(t *testing.T) {
+	assert := assert.New(t)
+
+	g := newTestGenerator(t, nil)
+	g.Enqueue(context.Background(), 1, 0, 1, 1, PriorityLow)
+	g.Enqueue(context.Background(), 2, 0, 2, 2, PriorityLow)
+
+	var isCancelled bool
+	g.cancels[&job{ProgramID: 1, UserID: 3}] = func() { isCancelled = true }
+
+	g.Cancel(1)
+
+	assert.True(isCancelled)
+	assert.False(g.IsInQueue(1))
+	assert.True(g.IsInQueue(2))
+
+	n, _ := g.queue.Len()
+	assert.Equal(1, n)
+}
+
 func TestQueue_generate(t *testing.T) {
 	assert := assert.New(t)
 
 	programID := 1
+	definitionID := 3
 	userID := 2
 
 	score := float64(100)
 
 	t.Run("empty structures", func(t *testing.T) {
 		db, mock := db.New()
-		g := Generator{db: db}
+		g := NewGenerator(db).(*Generator)
 
 		mock.MatchExpectationsInOrder(false)
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(programID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id"}))
+			WithArgs(definitionID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}))
 
 		mock.ExpectQuery("SELECT .+ FROM user_scores").
 			WithArgs(userID).
 			WillReturnRows(sqlmock.NewRows([]string{"syllabus_id", "score"}).AddRow(1, 100))
 
-		assert.Nil(g.generate(programID, userID, 0))
+		assert.Nil(g.generate(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID}))
 		assert.Nil(mock.ExpectationsWereMet())
 	})
 
 	t.Run("empty assignments", func(t *testing.T) {
 		db, mock := db.New()
-		g := Generator{db: db}
+		g := NewGenerator(db).(*Generator)
 
 		mock.MatchExpectationsInOrder(false)
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(programID).
-			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id"}).AddRow(1, nil, 1))
+			WithArgs(definitionID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}).AddRow(1, nil, 1, 1))
 
 		mock.ExpectQuery("SELECT .+ FROM user_scores").
 			WithArgs(userID).
 			WillReturnRows(sqlmock.NewRows([]string{"syllabus_id", "score"}))
 
-		assert.Nil(g.generate(programID, userID, 0))
+		assert.Nil(g.generate(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID}))
 		assert.Nil(mock.ExpectationsWereMet())
 	})
 
 	t.Run("insert", func(t *testing.T) {
 		db, mock := db.New()
-		g := Generator{db: db}
+		g := NewGenerator(db).(*Generator)
 
 		mock.MatchExpectationsInOrder(false)
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(programID).
+			WithArgs(definitionID).
 			WillReturnRows(
-				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id"}).
-					AddRow(1, nil, 1).
-					AddRow(2, 1, 2),
+				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}).
+					AddRow(1, nil, nil, 1).
+					AddRow(2, 1, nil, 1).
+					AddRow(3, 2, 2, 1),
 			)
 
 		mock.ExpectQuery("SELECT .+ FROM user_scores").
@@ -78,31 +134,32 @@ func TestQueue_generate(t *testing.T) {
 
 		scorecardID := 1
 		mock.ExpectQuery("INSERT INTO scorecards").
-			WithArgs(programID, userID, score).
+			WithArgs(programID, definitionID, userID, score).
 			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(scorecardID))
 
+		// every node is kept, not only the ones right under the roots
 		mock.ExpectExec("INSERT INTO scorecard_items").
-			WithArgs(scorecardID, 2, score).
+			WithArgs(scorecardID, 1, score, scorecardID, 2, score, scorecardID, 3, score).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 
 		mock.ExpectCommit()
 
-		assert.Nil(g.generate(programID, userID, 0))
+		assert.Nil(g.generate(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID}))
 		assert.Nil(mock.ExpectationsWereMet())
 	})
 
 	t.Run("update", func(t *testing.T) {
 		db, mock := db.New()
-		g := Generator{db: db}
+		g := NewGenerator(db).(*Generator)
 
 		mock.MatchExpectationsInOrder(false)
 
 		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
-			WithArgs(programID).
+			WithArgs(definitionID).
 			WillReturnRows(
-				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id"}).
-					AddRow(1, nil, 1).
-					AddRow(2, 1, 2),
+				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}).
+					AddRow(1, nil, 1, 1).
+					AddRow(2, 1, 2, 1),
 			)
 
 		mock.ExpectQuery("SELECT .+ FROM user_scores").
@@ -121,13 +178,99 @@ func TestQueue_generate(t *testing.T) {
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		mock.ExpectExec("INSERT INTO scorecard_items").
-			WithArgs(scorecardID, 2, score).
+			WithArgs(scorecardID, 1, score, scorecardID, 2, score).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		mock.ExpectCommit()
 
-		assert.Nil(g.generate(programID, userID, scorecardID))
+		assert.Nil(g.generate(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID, ScorecardID: scorecardID}))
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+
+	t.Run("cancelled while running", func(t *testing.T) {
+		db, mock := db.New()
+		g := newTestGenerator(t, db)
+		g.store.Add(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID, ScorecardID: 1})
+
+		mock.MatchExpectationsInOrder(false)
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(definitionID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}).
+					AddRow(1, nil, 1, 1).
+					AddRow(2, 1, 2, 1),
+			)
+
+		mock.ExpectQuery("SELECT .+ FROM user_scores").
+			WithArgs(userID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"syllabus_id", "score"}).
+					AddRow(1, 0).
+					AddRow(2, 100),
+			)
+
+		mock.ExpectBegin()
+
+		scorecardID := 1
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(score, scorecardID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		// The statement only returns once the job is cancelled, which happens as soon as the statement is matched
+		mock.ExpectExec("INSERT INTO scorecard_items").
+			WithArgs(cancelArg{g, programID}, 1, score, scorecardID, 2, score).
+			WillDelayFor(time.Hour).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		// The transaction is rolled back by database/sql itself once the context is cancelled, so no commit is expected
+
+		assert.Nil(g.process(context.Background()))
 		assert.Nil(mock.ExpectationsWereMet())
+		assert.Equal(uint32(0), g.Stats().InQueue)
 	})
 
+	t.Run("cancelled on another instance", func(t *testing.T) {
+		db, mock := db.New()
+		g := NewGenerator(db).(*Generator)
+		g.store.Add(context.Background(), &job{ProgramID: programID, DefinitionID: definitionID, UserID: userID, ScorecardID: 1})
+		j, _ := g.store.Pop(context.Background())
+
+		mock.MatchExpectationsInOrder(false)
+
+		mock.ExpectQuery("SELECT .+ FROM scorecard_structures").
+			WithArgs(definitionID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"id", "parent_id", "syllabus_id", "weight"}).
+					AddRow(1, nil, 1, 1).
+					AddRow(2, 1, 2, 1),
+			)
+
+		mock.ExpectQuery("SELECT .+ FROM user_scores").
+			WithArgs(userID).
+			WillReturnRows(
+				sqlmock.NewRows([]string{"syllabus_id", "score"}).
+					AddRow(1, 0).
+					AddRow(2, 100),
+			)
+
+		mock.ExpectBegin()
+
+		scorecardID := 1
+		mock.ExpectExec("UPDATE scorecards").
+			WithArgs(score, scorecardID).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectExec("INSERT INTO scorecard_items").
+			WithArgs(scorecardID, 1, score, scorecardID, 2, score).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		mock.ExpectRollback()
+
+		// Only the store knows about the cancel, the context of the job is still alive
+		g.store.Cancel(context.Background(), programID)
+
+		assert.Nil(g.generate(context.Background(), j))
+		assert.Nil(mock.ExpectationsWereMet())
+	})
 }
@@ -4,12 +4,14 @@ type Node struct {
 	ID       int
 	ParentID *int `db:"parent_id"`
 	Score    float64
+	Weight   float64 // how much the node counts in the score of its parent
 	filled   bool
 	children []*Node
 }
 
 type Reducer struct {
-	m map[int]*Node
+	m     map[int]*Node
+	nodes []*Node // in the order they were set, which is also the order children are reduced in
 }
 
 func NewReducer() *Reducer {
@@ -20,8 +22,11 @@ func NewReducer() *Reducer {
 
 func (r *Reducer) SetNodes(nodes []*Node) {
 	r.m = make(map[int]*Node, len(nodes))
+	r.nodes = nodes
 
 	for _, node := range nodes {
+		node.filled = false
+		node.children = nil
 		r.m[node.ID] = node
 	}
 
@@ -35,7 +40,7 @@ func (r *Reducer) SetNodes(nodes []*Node) {
 }
 
 func (r *Reducer) Reduce() {
-	for _, node := range r.m {
+	for _, node := range r.nodes {
 		if node.ParentID == nil {
 			r.fillScore(node)
 		}
@@ -44,7 +49,7 @@ func (r *Reducer) Reduce() {
 
 func (r *Reducer) GetRoots() []*Node {
 	var nodes []*Node
-	for _, node := range r.m {
+	for _, node := range r.nodes {
 		if node.ParentID == nil {
 			nodes = append(nodes, node)
 		}
@@ -54,7 +59,7 @@ func (r *Reducer) GetRoots() []*Node {
 
 func (r *Reducer) GetByParentID(parentID int) []*Node {
 	var nodes []*Node
-	for _, node := range r.m {
+	for _, node := range r.nodes {
 		if node.ParentID != nil && *node.ParentID == parentID {
 			nodes = append(nodes, node)
 		}
@@ -62,6 +67,84 @@ func (r *Reducer) GetByParentID(parentID int) []*Node {
 	return nodes
 }
 
+type WalkOrder int
+
+const (
+	PreOrder WalkOrder = iota
+	PostOrder
+)
+
+// WalkFunc is called for every node, roots have a depth of 0. Returning false stops the walk.
+type WalkFunc func(node *Node, depth int) bool
+
+// Walk visits every node reachable from the roots, siblings are visited in the order they were set
+func (r *Reducer) Walk(order WalkOrder, fn WalkFunc) {
+	for _, root := range r.GetRoots() {
+		if !r.walk(root, 0, order, fn) {
+			return
+		}
+	}
+}
+
+func (r *Reducer) walk(node *Node, depth int, order WalkOrder, fn WalkFunc) bool {
+	if order == PreOrder && !fn(node, depth) {
+		return false
+	}
+
+	for _, child := range node.children {
+		if !r.walk(child, depth+1, order, fn) {
+			return false
+		}
+	}
+
+	if order == PostOrder && !fn(node, depth) {
+		return false
+	}
+
+	return true
+}
+
+// Structure is a node of a scorecard structure. A structure with a syllabus takes the score of the syllabus.
+type Structure struct {
+	ID         int
+	ParentID   *int `db:"parent_id"`
+	SyllabusID *int `db:"syllabus_id"`
+	Weight     float64
+}
+
+// Reduce fills the structures with the scores, which are keyed by syllabus, and returns the overall score along with
+// every node in the order of the tree. The overall score is the weighted mean of the roots.
+func Reduce(structures []*Structure, scores map[int]float64) (float64, []*Node) {
+	nodes := make([]*Node, len(structures))
+	for i, structure := range structures {
+		var score float64
+		if structure.SyllabusID != nil {
+			score = scores[*structure.SyllabusID]
+		}
+
+		nodes[i] = &Node{
+			ID:       structure.ID,
+			ParentID: structure.ParentID,
+			Score:    score,
+			Weight:   structure.Weight,
+		}
+	}
+
+	reducer := NewReducer()
+	reducer.SetNodes(nodes)
+	reducer.Reduce()
+
+	score := weightedMean(reducer.GetRoots())
+
+	var result []*Node
+	reducer.Walk(PreOrder, func(node *Node, _ int) bool {
+		result = append(result, node)
+		return true
+	})
+
+	return score, result
+}
+
 func (r *Reducer) fillScore(parent *Node) {
 	if parent.filled {
 		return
@@ -72,11 +155,23 @@ func (r *Reducer) fillScore(parent *Node) {
 		return
 	}
 
-	weight := 100 / float64(len(parent.children))
 	for _, child := range parent.children {
 		r.fillScore(child)
-		parent.Score += (child.Score * weight) / 100
 	}
+	parent.Score = weightedMean(parent.children)
 
 	parent.filled = true
 }
+
+// weightedMean returns 0 if the nodes don't weigh anything
+func weightedMean(nodes []*Node) float64 {
+	var sum, total float64
+	for _, node := range nodes {
+		sum += node.Score * node.Weight
+		total += node.Weight
+	}
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
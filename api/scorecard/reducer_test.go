@@ -38,18 +38,20 @@ func TestReducerReduce(t *testing.T) {
 		ID:       2,
 		ParentID: &node1.ID,
 		Score:    100,
+		Weight:   1,
 	}
 	node3 := Node{
 		ID:       3,
 		ParentID: &node1.ID,
 		Score:    50,
+		Weight:   3,
 	}
 
 	r := NewReducer()
 	r.SetNodes([]*Node{&node3, &node1, &node2})
 	r.Reduce()
 
-	assert.Equal(t, (node2.Score+node3.Score)/2, node1.Score)
+	assert.Equal(t, (node2.Score*node2.Weight+node3.Score*node3.Weight)/4, node1.Score)
 
 	// The following scenario should never occur
 	originalScore := node1.Score
@@ -98,3 +100,104 @@ func TestReducerGetByParentID(t *testing.T) {
 	assert.Len(t, nodes, 1)
 	assert.Equal(t, nodes[0].ID, node2.ID)
 }
+
+func TestReducerOrder(t *testing.T) {
+	newNodes := func() []*Node {
+		var nodes []*Node
+		for i := 1; i <= 20; i++ {
+			nodes = append(nodes, &Node{ID: i})
+		}
+		for i := 21; i <= 40; i++ {
+			parentID := (i % 20) + 1
+			nodes = append(nodes, &Node{ID: i, ParentID: &parentID, Score: 100 / float64(i), Weight: 1})
+		}
+		return nodes
+	}
+
+	r := NewReducer()
+	r.SetNodes(newNodes())
+	r.Reduce()
+
+	roots := r.GetRoots()
+	for i, node := range roots {
+		assert.Equal(t, i+1, node.ID)
+	}
+
+	children := r.GetByParentID(2)
+	assert.Len(t, children, 1)
+	assert.Equal(t, 21, children[0].ID)
+
+	for range 10 {
+		r2 := NewReducer()
+		r2.SetNodes(newNodes())
+		r2.Reduce()
+		for i, node := range r2.GetRoots() {
+			assert.Equal(t, roots[i].ID, node.ID)
+			assert.Equal(t, roots[i].Score, node.Score)
+		}
+	}
+}
+
+func TestReducerWalk(t *testing.T) {
+	node1 := Node{ID: 1}
+	node2 := Node{ID: 2, ParentID: &node1.ID}
+	node3 := Node{ID: 3, ParentID: &node2.ID}
+	node4 := Node{ID: 4, ParentID: &node1.ID}
+	node5 := Node{ID: 5}
+
+	r := NewReducer()
+	r.SetNodes([]*Node{&node1, &node2, &node3, &node4, &node5})
+
+	t.Run("pre-order", func(t *testing.T) {
+		var visited [][2]int
+		r.Walk(PreOrder, func(node *Node, depth int) bool {
+			visited = append(visited, [2]int{node.ID, depth})
+			return true
+		})
+		assert.Equal(t, [][2]int{{1, 0}, {2, 1}, {3, 2}, {4, 1}, {5, 0}}, visited)
+	})
+
+	t.Run("post-order", func(t *testing.T) {
+		var visited [][2]int
+		r.Walk(PostOrder, func(node *Node, depth int) bool {
+			visited = append(visited, [2]int{node.ID, depth})
+			return true
+		})
+		assert.Equal(t, [][2]int{{3, 2}, {2, 1}, {4, 1}, {1, 0}, {5, 0}}, visited)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		var visited []int
+		r.Walk(PreOrder, func(node *Node, depth int) bool {
+			visited = append(visited, node.ID)
+			return node.ID != 3
+		})
+		assert.Equal(t, []int{1, 2, 3}, visited)
+	})
+}
+
+func TestReduce(t *testing.T) {
+	id := func(v int) *int { return &v }
+
+	structures := []*Structure{
+		{ID: 1, Weight: 1},
+		{ID: 2, SyllabusID: id(2), Weight: 3},
+		{ID: 3, ParentID: id(1), Weight: 1},
+		{ID: 4, ParentID: id(3), SyllabusID: id(1), Weight: 1},
+		{ID: 5, ParentID: id(3), SyllabusID: id(3), Weight: 2},
+	}
+
+	score, nodes := Reduce(structures, map[int]float64{1: 80, 2: 40, 3: 50})
+	assert.Equal(t, float64(60*1+40*3)/4, score)
+
+	var got [][2]float64
+	for _, node := range nodes {
+		got = append(got, [2]float64{float64(node.ID), node.Score})
+	}
+	assert.Equal(t, [][2]float64{{1, 60}, {3, 60}, {4, 80}, {5, 50}, {2, 40}}, got)
+
+	t.Run("without weight", func(t *testing.T) {
+		score, _ := Reduce([]*Structure{{ID: 1}}, nil)
+		assert.Equal(t, float64(0), score)
+	})
+}
@@ -0,0 +1,119 @@
+package scorecard
+
+import (
+	"context"
+	"time"
+
+	"github.com/brantem/scorecard/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
+)
+
+// ParseSchedule parses a standard 5-field cron expression, descriptors like @daily are supported as well. Schedules
+// are always evaluated in UTC.
+func ParseSchedule(expression string) (cron.Schedule, error) {
+	return cron.ParseStandard(expression)
+}
+
+type Scheduler struct {
+	db *sqlx.DB
+
+	generator GeneratorInterface
+	interval  time.Duration
+}
+
+func NewScheduler(db *sqlx.DB, generator GeneratorInterface) *Scheduler {
+	return &Scheduler{
+		db: db,
+
+		generator: generator,
+		interval:  time.Minute,
+	}
+}
+
+// Start checks the schedules every minute until ctx is done. The first check happens right away, so runs that were
+// missed while the server was down are picked up after a restart.
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.tick(ctx, time.Now().UTC())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// tick runs every schedule that is due. Each schedule runs at most once per tick, even if several runs were missed.
+// A run is claimed before it is enqueued, so only one instance runs it when several instances tick at the same time.
+func (s *Scheduler) tick(ctx context.Context, now time.Time) {
+	type Schedule struct {
+		ID         int
+		ProgramID  int        `db:"program_id"`
+		Expression string     `db:"expression"`
+		LastRunAt  *string    `db:"last_run_at"`
+		RunFrom    model.Time `db:"run_from"`
+	}
+
+	rows, err := s.db.QueryxContext(ctx, `
+		SELECT s.id, s.program_id, s.expression, s.last_run_at, COALESCE(s.last_run_at, s.created_at) AS run_from
+		FROM scorecard_schedules s
+		JOIN programs p ON p.id = s.program_id
+		WHERE s.is_enabled = TRUE
+		  AND p.is_finalized = FALSE
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("scorecard.Scheduler.tick")
+		return
+	}
+	defer rows.Close()
+
+	var schedules []*Schedule
+	for rows.Next() {
+		var schedule Schedule
+		if err := rows.StructScan(&schedule); err != nil {
+			log.Error().Err(err).Msg("scorecard.Scheduler.tick")
+			return
+		}
+		schedules = append(schedules, &schedule)
+	}
+	rows.Close()
+
+	for _, schedule := range schedules {
+		v, err := ParseSchedule(schedule.Expression)
+		if err != nil {
+			log.Error().Err(err).Int("id", schedule.ID).Msg("scorecard.Scheduler.tick")
+			continue
+		}
+
+		if v.Next(schedule.RunFrom.Time).After(now) {
+			continue
+		}
+
+		// The run belongs to whoever moves last_run_at away from the value that was read
+		res, err := s.db.ExecContext(ctx, `
+			UPDATE scorecard_schedules
+			SET last_run_at = ?
+			WHERE id = ?
+			  AND last_run_at IS ?
+		`, now.Format(time.DateTime), schedule.ID, schedule.LastRunAt)
+		if err != nil {
+			log.Error().Err(err).Int("id", schedule.ID).Msg("scorecard.Scheduler.tick")
+			continue
+		}
+		if n, err := res.RowsAffected(); err != nil || n != 1 {
+			continue
+		}
+
+		if _, err := EnqueueProgram(ctx, s.db, s.generator, schedule.ProgramID, 0); err != nil {
+			log.Error().Err(err).Int("id", schedule.ID).Msg("scorecard.Scheduler.tick")
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package scorecard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGenerator struct {
+	GeneratorInterface
+
+	enqueued [][4]int
+}
+
+func (g *fakeGenerator) Enqueue(ctx context.Context, programID, definitionID, userID, scorecardID int, priority Priority) EnqueueStatus {
+	g.enqueued = append(g.enqueued, [4]int{programID, definitionID, userID, scorecardID})
+	return EnqueueStatusQueued
+}
+
+func TestScheduler_tick(t *testing.T) {
+	db, mock := db.New()
+	generator := &fakeGenerator{}
+	s := NewScheduler(db, generator)
+
+	now := time.Date(2024, 1, 2, 0, 30, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT .+ FROM scorecard_schedules").
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "program_id", "expression", "last_run_at", "run_from"}).
+				AddRow(1, 1, "0 0 * * *", "2024-01-01 00:00:00", "2024-01-01 00:00:00"). // missed a run at 2024-01-02 00:00
+				AddRow(2, 2, "0 0 * * *", "2024-01-02 00:00:00", "2024-01-02 00:00:00"). // already ran
+				AddRow(3, 3, "invalid", nil, "2024-01-01 00:00:00").
+				AddRow(4, 4, "0 0 * * *", nil, "2024-01-01 00:00:00"), // claimed by another instance
+		)
+
+	mock.ExpectExec("UPDATE scorecard_schedules .+ last_run_at IS \\?").
+		WithArgs("2024-01-02 00:30:00", 1, "2024-01-01 00:00:00").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery("SELECT .+ FROM scorecard_definitions").
+		WithArgs(1, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"definition_id", "user_id", "scorecard_id"}).AddRow(1, 1, 1).AddRow(2, 2, 0))
+
+	mock.ExpectExec("UPDATE scorecard_schedules .+ last_run_at IS \\?").
+		WithArgs("2024-01-02 00:30:00", 4, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s.tick(context.Background(), now)
+
+	assert.Nil(t, mock.ExpectationsWereMet())
+	assert.Equal(t, [][4]int{{1, 1, 1, 1}, {1, 2, 2, 0}}, generator.enqueued)
+}
@@ -0,0 +1,167 @@
+package scorecard
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// refreshInterval is how often the lease of a running job is refreshed while it runs
+const refreshInterval = 20 * time.Second
+
+// store keeps track of the jobs that are pending or running. It decides whether a job is queued or merged into a
+// pending one and in which order the jobs are processed.
+type store interface {
+	Add(ctx context.Context, j *job) (EnqueueStatus, error)
+	Remove(ctx context.Context, j *job) error
+	// Pop marks the pending job with the highest priority as running. It returns nil if there are no pending jobs.
+	Pop(ctx context.Context) (*job, error)
+	Done(ctx context.Context, j *job) error
+	// Refresh extends the lease of the running job, so it isn't considered dead while it's still running
+	Refresh(ctx context.Context, j *job) error
+	// Cancel drops every pending job of the program and marks the running ones as cancelled. Running jobs are stopped
+	// by the Generator.
+	Cancel(ctx context.Context, programID int) error
+	// IsCancelled reports whether the running job was cancelled after it was popped
+	IsCancelled(ctx context.Context, j *job) (bool, error)
+	// Len returns the number of pending jobs
+	Len(ctx context.Context) (int, error)
+	Stats(ctx context.Context) (*GeneratorStats, error)
+	// InQueue returns which of the scorecards have a pending or running job
+	InQueue(ctx context.Context, scorecardIds []int) (map[int]bool, error)
+}
+
+type memoryStore struct {
+	mu        sync.Mutex
+	pending   []*job
+	running   []*job
+	cancelled map[*job]bool
+}
+
+var _ store = (*memoryStore)(nil)
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{cancelled: make(map[*job]bool)}
+}
+
+// Add merges the job into the pending one of the same user and definition if there is any, raising its priority if
+// needed. A job that is already running is not merged into, as the scores might have changed since it started.
+func (s *memoryStore) Add(_ context.Context, j *job) (EnqueueStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, v := range s.pending {
+		if v.ProgramID != j.ProgramID || v.DefinitionID != j.DefinitionID || v.UserID != j.UserID {
+			continue
+		}
+		if j.ScorecardID != 0 {
+			v.ScorecardID = j.ScorecardID
+		}
+		if j.Priority > v.Priority {
+			s.pending = slices.Delete(s.pending, i, i+1)
+			v.Priority = j.Priority
+			s.push(v)
+		}
+		return EnqueueStatusMerged, nil
+	}
+
+	s.push(j)
+	return EnqueueStatusQueued, nil
+}
+
+// push inserts the job after every pending job with the same or a higher priority. The caller must hold s.mu.
+func (s *memoryStore) push(j *job) {
+	i := len(s.pending)
+	for i > 0 && s.pending[i-1].Priority < j.Priority {
+		i--
+	}
+	s.pending = slices.Insert(s.pending, i, j)
+}
+
+func (s *memoryStore) Remove(_ context.Context, j *job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = slices.DeleteFunc(s.pending, func(v *job) bool {
+		return v.ProgramID == j.ProgramID && v.DefinitionID == j.DefinitionID && v.UserID == j.UserID
+	})
+	return nil
+}
+
+func (s *memoryStore) Pop(_ context.Context) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.pending) == 0 {
+		return nil, nil
+	}
+
+	j := s.pending[0]
+	s.pending = s.pending[1:]
+	s.running = append(s.running, j)
+	return j, nil
+}
+
+func (s *memoryStore) Done(_ context.Context, j *job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = slices.DeleteFunc(s.running, func(v *job) bool { return v == j })
+	delete(s.cancelled, j)
+	return nil
+}
+
+// Refresh does nothing, as the running jobs of a memoryStore can't outlive their process
+func (s *memoryStore) Refresh(_ context.Context, _ *job) error {
+	return nil
+}
+
+func (s *memoryStore) Cancel(_ context.Context, programID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = slices.DeleteFunc(s.pending, func(j *job) bool { return j.ProgramID == programID })
+	for _, j := range s.running {
+		if j.ProgramID == programID {
+			s.cancelled[j] = true
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) IsCancelled(_ context.Context, j *job) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cancelled[j], nil
+}
+
+func (s *memoryStore) Len(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending), nil
+}
+
+func (s *memoryStore) Stats(_ context.Context) (*GeneratorStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &GeneratorStats{
+		InQueue: uint32(len(s.pending) + len(s.running)),
+	}, nil
+}
+
+func (s *memoryStore) InQueue(_ context.Context, scorecardIds []int) (map[int]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m := make(map[int]bool, len(scorecardIds))
+	for _, j := range slices.Concat(s.pending, s.running) {
+		if slices.Contains(scorecardIds, j.ScorecardID) {
+			m[j.ScorecardID] = true
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,239 @@
+package scorecard
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	redisPendingKey   = "scorecard:jobs:pending"   // ZSET of "programID:definitionID:userID", ordered by priority and then by insertion
+	redisJobsKey      = "scorecard:jobs"           // HASH of "programID:definitionID:userID" -> scorecardID of the pending jobs
+	redisRunningKey   = "scorecard:jobs:running"   // ZSET of "programID:definitionID:userID:scorecardID:seq", scored by the last refresh of their lease
+	redisCancelledKey = "scorecard:jobs:cancelled" // ZSET of the running members that were cancelled, scored the same way
+	redisSeqKey       = "scorecard:jobs:seq"
+	redisCancelKey    = "scorecard:jobs:cancel" // Pub/Sub channel of the cancelled program IDs
+)
+
+// A running job whose lease isn't refreshed for this long is considered dead, e.g. because the instance that ran it
+// crashed
+const redisRunningTTL = 3 * refreshInterval
+
+// The score of a pending job is priorityWeight * (PriorityHigh - priority) + seq, so jobs with a higher priority are
+// popped first and jobs with the same priority are popped in the order they were added.
+const redisPriorityWeight = 1e12
+
+var redisAddScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score then
+  if tonumber(ARGV[2]) ~= 0 then
+    redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+  end
+  -- A job whose priority is raised goes behind the other jobs with the new priority, same as in memoryStore
+  local weight = tonumber(ARGV[3])
+  if weight * ARGV[4] < weight * math.floor(tonumber(score) / weight) then
+    redis.call('ZADD', KEYS[1], weight * ARGV[4] + redis.call('INCR', KEYS[3]), ARGV[1])
+  end
+  return 0
+end
+
+local seq = redis.call('INCR', KEYS[3])
+redis.call('ZADD', KEYS[1], tonumber(ARGV[3]) * ARGV[4] + seq, ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+var redisPopScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[4], '-inf', ARGV[2])
+redis.call('ZREMRANGEBYSCORE', KEYS[5], '-inf', ARGV[2])
+
+local popped = redis.call('ZPOPMIN', KEYS[1])
+if #popped == 0 then
+  return false
+end
+
+local member = popped[1]
+local scorecardID = redis.call('HGET', KEYS[2], member) or '0'
+redis.call('HDEL', KEYS[2], member)
+
+local running = member .. ':' .. scorecardID .. ':' .. redis.call('INCR', KEYS[3])
+redis.call('ZADD', KEYS[4], ARGV[1], running)
+return running
+`)
+
+var redisCancelScript = redis.NewScript(`
+local members = redis.call('ZRANGE', KEYS[1], 0, -1)
+for _, member in ipairs(members) do
+  if string.sub(member, 1, #ARGV[1]) == ARGV[1] then
+    redis.call('ZREM', KEYS[1], member)
+    redis.call('HDEL', KEYS[2], member)
+  end
+end
+
+local running = redis.call('ZRANGE', KEYS[3], 0, -1, 'WITHSCORES')
+for i = 1, #running, 2 do
+  if string.sub(running[i], 1, #ARGV[1]) == ARGV[1] then
+    redis.call('ZADD', KEYS[4], running[i + 1], running[i])
+  end
+end
+return 0
+`)
+
+type redisStore struct {
+	redis *redis.Client
+}
+
+var _ store = (*redisStore)(nil)
+
+func newRedisStore(client *redis.Client) *redisStore {
+	return &redisStore{client}
+}
+
+func redisMember(programID, definitionID, userID int) string {
+	return fmt.Sprintf("%d:%d:%d", programID, definitionID, userID)
+}
+
+func (s *redisStore) Add(ctx context.Context, j *job) (EnqueueStatus, error) {
+	keys := []string{redisPendingKey, redisJobsKey, redisSeqKey}
+	queued, err := redisAddScript.Run(ctx, s.redis, keys, redisMember(j.ProgramID, j.DefinitionID, j.UserID), j.ScorecardID, redisPriorityWeight, int(PriorityHigh-j.Priority)).Int()
+	if err != nil {
+		return EnqueueStatusSkipped, err
+	}
+	if queued == 1 {
+		return EnqueueStatusQueued, nil
+	}
+	return EnqueueStatusMerged, nil
+}
+
+func (s *redisStore) Remove(ctx context.Context, j *job) error {
+	member := redisMember(j.ProgramID, j.DefinitionID, j.UserID)
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, redisPendingKey, member)
+		pipe.HDel(ctx, redisJobsKey, member)
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) Pop(ctx context.Context) (*job, error) {
+	now := time.Now()
+	keys := []string{redisPendingKey, redisJobsKey, redisSeqKey, redisRunningKey, redisCancelledKey}
+	member, err := redisPopScript.Run(ctx, s.redis, keys, now.Unix(), now.Add(-redisRunningTTL).Unix()).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids [4]int
+	for i, v := range strings.SplitN(member, ":", 5)[:4] {
+		if ids[i], err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return &job{
+		ProgramID:    ids[0],
+		DefinitionID: ids[1],
+		UserID:       ids[2],
+		ScorecardID:  ids[3],
+		key:          member,
+	}, nil
+}
+
+func (s *redisStore) Done(ctx context.Context, j *job) error {
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, redisRunningKey, j.key)
+		pipe.ZRem(ctx, redisCancelledKey, j.key)
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) Refresh(ctx context.Context, j *job) error {
+	// XX keeps a job that was already done, or considered dead, from being added back
+	z := &redis.Z{Score: float64(time.Now().Unix()), Member: j.key}
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAddXX(ctx, redisRunningKey, z)
+		pipe.ZAddXX(ctx, redisCancelledKey, z)
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) Cancel(ctx context.Context, programID int) error {
+	keys := []string{redisPendingKey, redisJobsKey, redisRunningKey, redisCancelledKey}
+	return redisCancelScript.Run(ctx, s.redis, keys, fmt.Sprintf("%d:", programID)).Err()
+}
+
+func (s *redisStore) IsCancelled(ctx context.Context, j *job) (bool, error) {
+	err := s.redis.ZScore(ctx, redisCancelledKey, j.key).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *redisStore) Len(ctx context.Context) (int, error) {
+	n, err := s.redis.ZCard(ctx, redisPendingKey).Result()
+	return int(n), err
+}
+
+func (s *redisStore) Stats(ctx context.Context) (*GeneratorStats, error) {
+	min := strconv.FormatInt(time.Now().Add(-redisRunningTTL).Unix(), 10)
+
+	var pending, running *redis.IntCmd
+	_, err := s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.ZCard(ctx, redisPendingKey)
+		running = pipe.ZCount(ctx, redisRunningKey, "("+min, "+inf")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeneratorStats{
+		InQueue: uint32(pending.Val() + running.Val()),
+	}, nil
+}
+
+// InQueue reads the scorecards of every pending and running job at once, so listing a program doesn't scan the queue
+// once per scorecard
+func (s *redisStore) InQueue(ctx context.Context, scorecardIds []int) (map[int]bool, error) {
+	var pending *redis.StringSliceCmd
+	var running *redis.StringSliceCmd
+	_, err := s.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pending = pipe.HVals(ctx, redisJobsKey)
+		running = pipe.ZRangeByScore(ctx, redisRunningKey, &redis.ZRangeBy{
+			Min: "(" + strconv.FormatInt(time.Now().Add(-redisRunningTTL).Unix(), 10),
+			Max: "+inf",
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool, len(pending.Val())+len(running.Val()))
+	for _, v := range pending.Val() {
+		ids[v] = true
+	}
+	for _, v := range running.Val() {
+		if parts := strings.SplitN(v, ":", 5); len(parts) == 5 {
+			ids[parts[3]] = true
+		}
+	}
+
+	m := make(map[int]bool, len(scorecardIds))
+	for _, id := range scorecardIds {
+		if ids[strconv.Itoa(id)] {
+			m[id] = true
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,142 @@
+package scorecard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, s store) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	pop := func() *job {
+		j, err := s.Pop(ctx)
+		assert.Nil(err)
+		return j
+	}
+
+	status, _ := s.Add(ctx, &job{ProgramID: 1, UserID: 1, Priority: PriorityLow})
+	assert.Equal(EnqueueStatusQueued, status)
+	status, _ = s.Add(ctx, &job{ProgramID: 1, UserID: 2, Priority: PriorityLow})
+	assert.Equal(EnqueueStatusQueued, status)
+	status, _ = s.Add(ctx, &job{ProgramID: 1, UserID: 3, Priority: PriorityLow})
+	assert.Equal(EnqueueStatusQueued, status)
+	status, _ = s.Add(ctx, &job{ProgramID: 2, UserID: 4, ScorecardID: 4, Priority: PriorityHigh})
+	assert.Equal(EnqueueStatusQueued, status)
+
+	// merged without changing the order
+	status, _ = s.Add(ctx, &job{ProgramID: 1, UserID: 1, Priority: PriorityLow})
+	assert.Equal(EnqueueStatusMerged, status)
+
+	// merged and moved behind the other job with a high priority
+	status, _ = s.Add(ctx, &job{ProgramID: 1, UserID: 3, ScorecardID: 3, Priority: PriorityHigh})
+	assert.Equal(EnqueueStatusMerged, status)
+
+	stats, _ := s.Stats(ctx)
+	assert.Equal(uint32(4), stats.InQueue)
+	n, _ := s.Len(ctx)
+	assert.Equal(4, n)
+	m, _ := s.InQueue(ctx, []int{3, 4, 5})
+	assert.Equal(map[int]bool{3: true, 4: true}, m)
+
+	j := pop()
+	assert.Equal([3]int{2, 4, 4}, [3]int{j.ProgramID, j.UserID, j.ScorecardID})
+	assert.Nil(s.Refresh(ctx, j))
+	m, _ = s.InQueue(ctx, []int{4})
+	assert.True(m[4])
+	assert.Nil(s.Done(ctx, j))
+	m, _ = s.InQueue(ctx, []int{4})
+	assert.False(m[4])
+
+	j = pop()
+	assert.Equal([3]int{1, 3, 3}, [3]int{j.ProgramID, j.UserID, j.ScorecardID})
+	ok, _ := s.IsCancelled(ctx, j)
+	assert.False(ok)
+	assert.Nil(s.Cancel(ctx, 1)) // the pending jobs of program 1 are added back below
+	ok, _ = s.IsCancelled(ctx, j)
+	assert.True(ok)
+	assert.Nil(s.Done(ctx, j))
+	s.Add(ctx, &job{ProgramID: 1, UserID: 1, Priority: PriorityLow})
+	s.Add(ctx, &job{ProgramID: 1, UserID: 2, Priority: PriorityLow})
+
+	assert.Nil(s.Remove(ctx, &job{ProgramID: 1, UserID: 2}))
+	assert.Nil(s.Cancel(ctx, 2))
+
+	j = pop()
+	assert.Equal([3]int{1, 1, 0}, [3]int{j.ProgramID, j.UserID, j.ScorecardID})
+	stats, _ = s.Stats(ctx)
+	assert.Equal(uint32(1), stats.InQueue)
+	assert.Nil(s.Done(ctx, j))
+
+	s.Add(ctx, &job{ProgramID: 1, UserID: 5})
+	s.Add(ctx, &job{ProgramID: 2, UserID: 6})
+	assert.Nil(s.Cancel(ctx, 1))
+	n, _ = s.Len(ctx)
+	assert.Equal(1, n)
+
+	j = pop()
+	assert.Equal(6, j.UserID)
+	assert.Nil(s.Done(ctx, j))
+	assert.Nil(pop())
+
+	// the jobs of the same user in different definitions aren't merged
+	status, _ = s.Add(ctx, &job{ProgramID: 1, DefinitionID: 1, UserID: 7})
+	assert.Equal(EnqueueStatusQueued, status)
+	status, _ = s.Add(ctx, &job{ProgramID: 1, DefinitionID: 2, UserID: 7, ScorecardID: 8})
+	assert.Equal(EnqueueStatusQueued, status)
+
+	j = pop()
+	assert.Equal([3]int{1, 7, 0}, [3]int{j.DefinitionID, j.UserID, j.ScorecardID})
+	assert.Nil(s.Done(ctx, j))
+	j = pop()
+	assert.Equal([3]int{2, 7, 8}, [3]int{j.DefinitionID, j.UserID, j.ScorecardID})
+	assert.Nil(s.Done(ctx, j))
+	assert.Nil(pop())
+
+	stats, _ = s.Stats(ctx)
+	assert.Equal(uint32(0), stats.InQueue)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, newMemoryStore())
+}
+
+func TestRedisStore(t *testing.T) {
+	m := miniredis.RunT(t)
+	testStore(t, newRedisStore(redis.NewClient(&redis.Options{Addr: m.Addr()})))
+}
+
+func TestRedisStore_Refresh(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	m := miniredis.RunT(t)
+	s := newRedisStore(redis.NewClient(&redis.Options{Addr: m.Addr()}))
+
+	s.Add(ctx, &job{ProgramID: 1, UserID: 1, ScorecardID: 1})
+	s.Add(ctx, &job{ProgramID: 1, UserID: 2, ScorecardID: 2})
+	j1, _ := s.Pop(ctx)
+	j2, _ := s.Pop(ctx)
+
+	// both jobs started long enough ago to be considered dead, but only the first one is still alive
+	expiredAt := float64(time.Now().Add(-2 * redisRunningTTL).Unix())
+	m.ZAdd(redisRunningKey, expiredAt, j1.key)
+	m.ZAdd(redisRunningKey, expiredAt, j2.key)
+	assert.Nil(s.Refresh(ctx, j1))
+
+	inQueue, _ := s.InQueue(ctx, []int{1, 2})
+	assert.Equal(map[int]bool{1: true}, inQueue)
+	stats, _ := s.Stats(ctx)
+	assert.Equal(uint32(1), stats.InQueue)
+
+	// a job that is done isn't added back
+	assert.Nil(s.Done(ctx, j1))
+	assert.Nil(s.Refresh(ctx, j1))
+	stats, _ = s.Stats(ctx)
+	assert.Equal(uint32(0), stats.InQueue)
+}
@@ -43,6 +43,9 @@ func main() {
 	generator := scorecard.NewGenerator(db)
 	generator.Start()
 
+	scheduler := scorecard.NewScheduler(db, generator)
+	scheduler.Start(ctx)
+
 	app := fiber.New(fiber.Config{
 		AppName:               constant.AppID,
 		DisableStartupMessage: os.Getenv("APP_ENV") == "production",
@@ -61,7 +64,7 @@ func main() {
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:  "*",
-		AllowHeaders:  "Content-Type",
+		AllowHeaders:  "Content-Type, X-Actor",
 		ExposeHeaders: "X-Total-Count",
 	}))
 	app.Use(compress.New(compress.Config{
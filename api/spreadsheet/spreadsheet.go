@@ -0,0 +1,90 @@
+// Package spreadsheet reads and writes tables as CSV or XLSX files
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("spreadsheet: unsupported format")
+
+// FormatFromFilename returns the format of a file based on its extension
+func FormatFromFilename(filename string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// Read returns the rows of the file, only the first sheet of an XLSX file is read. Trailing empty cells might be
+// omitted, so rows can have different lengths.
+func Read(r io.Reader, format Format) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		return cr.ReadAll()
+	case FormatXLSX:
+		f, err := excelize.OpenReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetRows(f.GetSheetName(0))
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// Write writes the rows into a single sheet. Values are kept as they are in an XLSX file, so numbers stay numbers, and
+// are formatted with fmt.Sprint in a CSV file. A nil value is written as an empty cell.
+func Write(w io.Writer, format Format, rows [][]any) error {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				if v != nil {
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatXLSX:
+		f := excelize.NewFile()
+		defer f.Close()
+		sheet := f.GetSheetName(0)
+		for i, row := range rows {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+				return err
+			}
+		}
+		return f.Write(w)
+	}
+	return ErrUnsupportedFormat
+}
@@ -0,0 +1,73 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestFormatFromFilename(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := FormatFromFilename("scores.CSV")
+	assert.Nil(err)
+	assert.Equal(FormatCSV, format)
+
+	format, err = FormatFromFilename("scores.xlsx")
+	assert.Nil(err)
+	assert.Equal(FormatXLSX, format)
+
+	_, err = FormatFromFilename("scores.ods")
+	assert.Equal(ErrUnsupportedFormat, err)
+}
+
+func TestRead(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("csv", func(t *testing.T) {
+		rows, err := Read(strings.NewReader("User,A1,A2\nUser 1, 90\n"), FormatCSV)
+		assert.Nil(err)
+		assert.Equal([][]string{{"User", "A1", "A2"}, {"User 1", "90"}}, rows)
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		f := excelize.NewFile()
+		f.SetSheetRow("Sheet1", "A1", &[]any{"User", "A1", "A2"})
+		f.SetSheetRow("Sheet1", "A2", &[]any{"User 1", 90})
+		var buf bytes.Buffer
+		assert.Nil(f.Write(&buf))
+
+		rows, err := Read(&buf, FormatXLSX)
+		assert.Nil(err)
+		assert.Equal([][]string{{"User", "A1", "A2"}, {"User 1", "90"}}, rows)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	rows := [][]any{{"User", "A1", "A2"}, {"User 1", 90.5, nil}}
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(Write(&buf, FormatCSV, rows))
+		assert.Equal("User,A1,A2\nUser 1,90.5,\n", buf.String())
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(Write(&buf, FormatXLSX, rows))
+
+		f, err := excelize.OpenReader(&buf)
+		assert.Nil(err)
+		typ, _ := f.GetCellType("Sheet1", "B2")
+		assert.Equal(excelize.CellTypeUnset, typ) // numbers are stored without a type
+
+		result, err := f.GetRows("Sheet1")
+		assert.Nil(err)
+		assert.Equal([][]string{{"User", "A1", "A2"}, {"User 1", "90.5"}}, result)
+	})
+}
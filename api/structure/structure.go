@@ -0,0 +1,253 @@
+// Package structure reads and writes scorecard structure trees as JSON or YAML files and compares them
+package structure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+var ErrUnsupportedFormat = errors.New("structure: unsupported format")
+
+// FormatFromFilename returns the format of a file based on its extension
+func FormatFromFilename(filename string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+type Document struct {
+	Structures []*Node `json:"structures" yaml:"structures"`
+}
+
+// SyllabusRef points to a syllabus by its code or, if it doesn't have one, by the titles on the path to it, e.g.
+// ["Syllabus 1", "Assignment 1"]. Unlike the ID, both stay the same when the syllabuses are copied into another program.
+type SyllabusRef struct {
+	Code string   `json:"code,omitempty" yaml:"code,omitempty"`
+	Path []string `json:"path,omitempty" yaml:"path,omitempty,flow"`
+}
+
+func (r *SyllabusRef) String() string {
+	if r.Code != "" {
+		return r.Code
+	}
+	return strings.Join(r.Path, " / ")
+}
+
+type Node struct {
+	ID       int          `json:"-" yaml:"-"`
+	Title    string       `json:"title" yaml:"title"`
+	Syllabus *SyllabusRef `json:"syllabus,omitempty" yaml:"syllabus,omitempty"`
+	// Weight is nil when it's the default of 1
+	Weight   *float64 `json:"weight,omitempty" yaml:"weight,omitempty"`
+	IsLinked bool     `json:"isLinked,omitempty" yaml:"isLinked,omitempty"`
+	Children []*Node  `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// GetWeight returns the weight of the node, which is 1 if it isn't set
+func (n *Node) GetWeight() float64 {
+	if n.Weight == nil {
+		return 1
+	}
+	return *n.Weight
+}
+
+// Read decodes the document and checks that every node has a title, that every syllabus reference has either a code
+// or a path and that every weight is positive
+func Read(r io.Reader, format Format) (*Document, error) {
+	var doc Document
+	switch format {
+	case FormatJSON:
+		d := json.NewDecoder(r)
+		d.DisallowUnknownFields()
+		if err := d.Decode(&doc); err != nil {
+			return nil, err
+		}
+	case FormatYAML:
+		d := yaml.NewDecoder(r)
+		d.KnownFields(true)
+		if err := d.Decode(&doc); err != nil && err != io.EOF {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	var walk func(nodes []*Node, parents []string) error
+	walk = func(nodes []*Node, parents []string) error {
+		for _, node := range nodes {
+			node.Title = strings.TrimSpace(node.Title)
+			if node.Title == "" {
+				return fmt.Errorf("a structure under %q doesn't have a title", strings.Join(parents, " / "))
+			}
+			path := append(parents[:len(parents):len(parents)], node.Title)
+			if ref := node.Syllabus; ref != nil && (ref.Code == "") == (len(ref.Path) == 0) {
+				return fmt.Errorf("the syllabus of %q needs either a code or a path", strings.Join(path, " / "))
+			}
+			if node.Weight != nil && *node.Weight <= 0 {
+				return fmt.Errorf("the weight of %q should be greater than 0", strings.Join(path, " / "))
+			}
+			if err := walk(node.Children, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(doc.Structures, nil); err != nil {
+		return nil, err
+	}
+
+	return &doc, nil
+}
+
+// Write encodes the document with an indentation of 2 spaces
+func Write(w io.Writer, format Format, doc *Document) error {
+	switch format {
+	case FormatJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(doc)
+	case FormatYAML:
+		e := yaml.NewEncoder(w)
+		e.SetIndent(2)
+		if err := e.Encode(doc); err != nil {
+			return err
+		}
+		return e.Close()
+	}
+	return ErrUnsupportedFormat
+}
+
+const (
+	FieldSyllabus = "syllabus"
+	FieldWeight   = "weight"
+	FieldIsLinked = "isLinked"
+	FieldPosition = "position"
+)
+
+// Change is a node that is only in one of the trees or that is in both but differs. Before and After don't have
+// children, the changes under them are listed separately.
+type Change struct {
+	Path   []string `json:"path"`
+	Before *Node    `json:"before"`
+	After  *Node    `json:"after"`
+	Fields []string `json:"fields,omitempty"`
+}
+
+type Diff struct {
+	Added   []*Change `json:"added"`
+	Removed []*Change `json:"removed"`
+	Changed []*Change `json:"changed"`
+}
+
+func (d *Diff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Compare matches the nodes by the titles on the path to them, titles that are used more than once under the same
+// parent are matched in order. A node that is removed or added takes the nodes under it along. The ID of every node in
+// next is set to the ID of the node it matches in current, or 0 if it's new, so the diff can be applied without
+// replacing the structures that are kept.
+func Compare(current, next []*Node) *Diff {
+	diff := Diff{
+		Added:   []*Change{},
+		Removed: []*Change{},
+		Changed: []*Change{},
+	}
+
+	var walk func(current, next []*Node, parents []string)
+	walk = func(current, next []*Node, parents []string) {
+		isMatched := make([]bool, len(current))
+		for i, node := range next {
+			path := append(parents[:len(parents):len(parents)], node.Title)
+
+			j := -1
+			for k, v := range current {
+				if !isMatched[k] && v.Title == node.Title {
+					j = k
+					break
+				}
+			}
+			if j == -1 {
+				node.ID = 0
+				addAll(&diff.Added, node, parents, false)
+				continue
+			}
+			isMatched[j] = true
+
+			before := current[j]
+			node.ID = before.ID
+
+			var fields []string
+			if !isSameRef(before.Syllabus, node.Syllabus) {
+				fields = append(fields, FieldSyllabus)
+			}
+			if before.GetWeight() != node.GetWeight() {
+				fields = append(fields, FieldWeight)
+			}
+			if before.IsLinked != node.IsLinked {
+				fields = append(fields, FieldIsLinked)
+			}
+			if i != j {
+				fields = append(fields, FieldPosition)
+			}
+			if len(fields) > 0 {
+				diff.Changed = append(diff.Changed, &Change{path, shallow(before), shallow(node), fields})
+			}
+
+			walk(before.Children, node.Children, path)
+		}
+
+		for j, node := range current {
+			if !isMatched[j] {
+				addAll(&diff.Removed, node, parents, true)
+			}
+		}
+	}
+	walk(current, next, nil)
+
+	return &diff
+}
+
+func addAll(changes *[]*Change, node *Node, parents []string, isRemoved bool) {
+	path := append(parents[:len(parents):len(parents)], node.Title)
+	if isRemoved {
+		*changes = append(*changes, &Change{Path: path, Before: shallow(node)})
+	} else {
+		*changes = append(*changes, &Change{Path: path, After: shallow(node)})
+	}
+	for _, child := range node.Children {
+		if !isRemoved {
+			child.ID = 0
+		}
+		addAll(changes, child, path, isRemoved)
+	}
+}
+
+func shallow(node *Node) *Node {
+	return &Node{ID: node.ID, Title: node.Title, Syllabus: node.Syllabus, Weight: node.Weight, IsLinked: node.IsLinked}
+}
+
+func isSameRef(a, b *SyllabusRef) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Code == b.Code && slices.Equal(a.Path, b.Path)
+}
@@ -0,0 +1,235 @@
+package structure
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatFromFilename(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := FormatFromFilename("structures.JSON")
+	assert.Nil(err)
+	assert.Equal(FormatJSON, format)
+
+	format, err = FormatFromFilename("structures.yml")
+	assert.Nil(err)
+	assert.Equal(FormatYAML, format)
+
+	_, err = FormatFromFilename("structures.xml")
+	assert.Equal(ErrUnsupportedFormat, err)
+}
+
+func TestRead(t *testing.T) {
+	assert := assert.New(t)
+
+	weight := 2.0
+	expected := &Document{
+		Structures: []*Node{
+			{
+				Title: "Total",
+				Children: []*Node{
+					{Title: "Assignment 1", Syllabus: &SyllabusRef{Code: "A1"}, Weight: &weight},
+					{Title: "Assignment 2", Syllabus: &SyllabusRef{Path: []string{"Syllabus 1", "Assignment 2"}}, IsLinked: true},
+				},
+			},
+		},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		doc, err := Read(strings.NewReader(`{"structures":[{"title":"Total","children":[{"title":"Assignment 1","syllabus":{"code":"A1"},"weight":2},{"title":" Assignment 2 ","syllabus":{"path":["Syllabus 1","Assignment 2"]},"isLinked":true}]}]}`), FormatJSON)
+		assert.Nil(err)
+		assert.Equal(expected, doc)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		doc, err := Read(strings.NewReader(strings.Join([]string{
+			"structures:",
+			"  - title: Total",
+			"    children:",
+			"      - title: Assignment 1",
+			"        syllabus:",
+			"          code: A1",
+			"        weight: 2",
+			"      - title: Assignment 2",
+			"        syllabus:",
+			"          path: [Syllabus 1, Assignment 2]",
+			"        isLinked: true",
+		}, "\n")), FormatYAML)
+		assert.Nil(err)
+		assert.Equal(expected, doc)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"structures":[{"title":"Total","position":2}]}`), FormatJSON)
+		assert.NotNil(err)
+	})
+
+	t.Run("empty title", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"structures":[{"title":"Total","children":[{"title":" "}]}]}`), FormatJSON)
+		assert.EqualError(err, `a structure under "Total" doesn't have a title`)
+	})
+
+	t.Run("invalid syllabus", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"structures":[{"title":"Total","syllabus":{"code":"A1","path":["Syllabus 1"]}}]}`), FormatJSON)
+		assert.EqualError(err, `the syllabus of "Total" needs either a code or a path`)
+	})
+
+	t.Run("invalid weight", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"structures":[{"title":"Total","children":[{"title":"Quiz","weight":0}]}]}`), FormatJSON)
+		assert.EqualError(err, `the weight of "Total / Quiz" should be greater than 0`)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	weight := 2.0
+	doc := &Document{
+		Structures: []*Node{
+			{
+				ID:    1,
+				Title: "Total",
+				Children: []*Node{
+					{ID: 2, Title: "Assignment 1", Syllabus: &SyllabusRef{Code: "A1"}, Weight: &weight},
+					{ID: 3, Title: "Assignment 2", Syllabus: &SyllabusRef{Path: []string{"Syllabus 1", "Assignment 2"}}, IsLinked: true},
+				},
+			},
+		},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(Write(&buf, FormatJSON, doc))
+		assert.Equal(strings.Join([]string{
+			`{`,
+			`  "structures": [`,
+			`    {`,
+			`      "title": "Total",`,
+			`      "children": [`,
+			`        {`,
+			`          "title": "Assignment 1",`,
+			`          "syllabus": {`,
+			`            "code": "A1"`,
+			`          },`,
+			`          "weight": 2`,
+			`        },`,
+			`        {`,
+			`          "title": "Assignment 2",`,
+			`          "syllabus": {`,
+			`            "path": [`,
+			`              "Syllabus 1",`,
+			`              "Assignment 2"`,
+			`            ]`,
+			`          },`,
+			`          "isLinked": true`,
+			`        }`,
+			`      ]`,
+			`    }`,
+			`  ]`,
+			`}`,
+			``,
+		}, "\n"), buf.String())
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.Nil(Write(&buf, FormatYAML, doc))
+		assert.Equal(strings.Join([]string{
+			"structures:",
+			"  - title: Total",
+			"    children:",
+			"      - title: Assignment 1",
+			"        syllabus:",
+			"          code: A1",
+			"        weight: 2",
+			"      - title: Assignment 2",
+			"        syllabus:",
+			"          path: [Syllabus 1, Assignment 2]",
+			"        isLinked: true",
+			"",
+		}, "\n"), buf.String())
+	})
+}
+
+func TestCompare(t *testing.T) {
+	assert := assert.New(t)
+
+	weight := 2.0
+	current := []*Node{
+		{
+			ID:    1,
+			Title: "Total",
+			Children: []*Node{
+				{ID: 2, Title: "Assignment 1", Syllabus: &SyllabusRef{Code: "A1"}},
+				{ID: 3, Title: "Assignment 2", Syllabus: &SyllabusRef{Code: "A2"}},
+				{ID: 4, Title: "Quizzes", Children: []*Node{{ID: 5, Title: "Quiz 1", Syllabus: &SyllabusRef{Code: "Q1"}}}},
+				{ID: 6, Title: "Project", Syllabus: &SyllabusRef{Code: "P"}},
+			},
+		},
+	}
+	next := []*Node{
+		{
+			Title: "Total",
+			Children: []*Node{
+				{Title: "Assignment 2", Syllabus: &SyllabusRef{Code: "A2"}},
+				{Title: "Assignment 1", Syllabus: &SyllabusRef{Path: []string{"Syllabus 1", "Assignment 1"}}},
+				{Title: "Exams", Children: []*Node{{Title: "Final", Syllabus: &SyllabusRef{Code: "F"}}}},
+				{Title: "Project", Syllabus: &SyllabusRef{Code: "P"}, Weight: &weight},
+			},
+		},
+	}
+
+	diff := Compare(current, next)
+	assert.Equal(&Diff{
+		Added: []*Change{
+			{Path: []string{"Total", "Exams"}, After: &Node{Title: "Exams"}},
+			{Path: []string{"Total", "Exams", "Final"}, After: &Node{Title: "Final", Syllabus: &SyllabusRef{Code: "F"}}},
+		},
+		Removed: []*Change{
+			{Path: []string{"Total", "Quizzes"}, Before: &Node{ID: 4, Title: "Quizzes"}},
+			{Path: []string{"Total", "Quizzes", "Quiz 1"}, Before: &Node{ID: 5, Title: "Quiz 1", Syllabus: &SyllabusRef{Code: "Q1"}}},
+		},
+		Changed: []*Change{
+			{
+				Path:   []string{"Total", "Assignment 2"},
+				Before: &Node{ID: 3, Title: "Assignment 2", Syllabus: &SyllabusRef{Code: "A2"}},
+				After:  &Node{ID: 3, Title: "Assignment 2", Syllabus: &SyllabusRef{Code: "A2"}},
+				Fields: []string{FieldPosition},
+			},
+			{
+				Path:   []string{"Total", "Assignment 1"},
+				Before: &Node{ID: 2, Title: "Assignment 1", Syllabus: &SyllabusRef{Code: "A1"}},
+				After:  &Node{ID: 2, Title: "Assignment 1", Syllabus: &SyllabusRef{Path: []string{"Syllabus 1", "Assignment 1"}}},
+				Fields: []string{FieldSyllabus, FieldPosition},
+			},
+			{
+				Path:   []string{"Total", "Project"},
+				Before: &Node{ID: 6, Title: "Project", Syllabus: &SyllabusRef{Code: "P"}},
+				After:  &Node{ID: 6, Title: "Project", Syllabus: &SyllabusRef{Code: "P"}, Weight: &weight},
+				Fields: []string{FieldWeight},
+			},
+		},
+	}, diff)
+	assert.False(diff.IsEmpty())
+
+	// The matched nodes take the IDs of the current ones
+	assert.Equal(1, next[0].ID)
+	assert.Equal(3, next[0].Children[0].ID)
+	assert.Equal(2, next[0].Children[1].ID)
+	assert.Equal(0, next[0].Children[2].ID)
+	assert.Equal(0, next[0].Children[2].Children[0].ID)
+
+	t.Run("duplicate titles", func(t *testing.T) {
+		current := []*Node{{ID: 1, Title: "Quiz"}, {ID: 2, Title: "Quiz", Syllabus: &SyllabusRef{Code: "Q2"}}}
+		one := 1.0
+		next := []*Node{{Title: "Quiz", Weight: &one}, {Title: "Quiz", Syllabus: &SyllabusRef{Code: "Q2"}}}
+
+		assert.True(Compare(current, next).IsEmpty())
+		assert.Equal(1, next[0].ID)
+		assert.Equal(2, next[1].ID)
+	})
+}
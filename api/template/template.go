@@ -0,0 +1,256 @@
+package template
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/brantem/scorecard/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schema.json
+var schemaJSON string
+
+var schema = jsonschema.MustCompileString("schema.json", schemaJSON)
+
+// Document is the skeleton of a program. The syllabuses are nested the same way the syllabus structures are, so a root
+// syllabus belongs to the first structure and a syllabus one level below the last structure is an assignment. The first
+// grade scale is used by the default definition.
+type Document struct {
+	SyllabusStructures  []string              `json:"syllabusStructures"`
+	Syllabuses          []*Syllabus           `json:"syllabuses"`
+	ScorecardStructures []*ScorecardStructure `json:"scorecardStructures"`
+	GradeScales         []*GradeScale         `json:"gradeScales,omitempty"`
+}
+
+type Syllabus struct {
+	Title    string      `json:"title"`
+	Children []*Syllabus `json:"children,omitempty"`
+}
+
+type ScorecardStructure struct {
+	Title string `json:"title"`
+	// Syllabus is the path to the syllabus, e.g. ["Syllabus 1", "Assignment 1"]
+	Syllabus []string `json:"syllabus,omitempty"`
+	// Weight is the weight of the structure in the score of its parent, 1 if it's empty
+	Weight   *float64              `json:"weight,omitempty"`
+	Children []*ScorecardStructure `json:"children,omitempty"`
+}
+
+type GradeScale struct {
+	Title  string       `json:"title"`
+	Grades model.Grades `json:"grades"`
+}
+
+// Parse validates data against the schema and checks that the syllabuses fit the syllabus structures, that every
+// scorecard structure points to an existing syllabus and that the grade scales are valid.
+func Parse(data []byte) (*Document, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	if err := schema.Validate(v); err != nil {
+		return nil, err
+	}
+
+	var doc Document
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	// The Assignment row is only created along with the first structure
+	if len(doc.Syllabuses) > 0 && len(doc.SyllabusStructures) == 0 {
+		return nil, fmt.Errorf("syllabuses require at least one syllabus structure")
+	}
+
+	paths := make(map[string]bool)
+	titles := make([]map[string]bool, len(doc.SyllabusStructures)+1) // titles are unique per structure
+	var walkSyllabuses func(nodes []*Syllabus, parents []string) error
+	walkSyllabuses = func(nodes []*Syllabus, parents []string) error {
+		level := len(parents)
+		if len(nodes) > 0 && level > len(doc.SyllabusStructures) {
+			return fmt.Errorf("%q is nested deeper than the syllabus structures", strings.Join(parents, " / "))
+		}
+		for _, node := range nodes {
+			if titles[level] == nil {
+				titles[level] = make(map[string]bool)
+			}
+			if titles[level][node.Title] {
+				return fmt.Errorf("%q is used more than once on the same level", node.Title)
+			}
+			titles[level][node.Title] = true
+
+			path := append(parents[:len(parents):len(parents)], node.Title)
+			paths[strings.Join(path, "\x00")] = true
+			if err := walkSyllabuses(node.Children, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walkSyllabuses(doc.Syllabuses, nil); err != nil {
+		return nil, err
+	}
+
+	var walkStructures func(nodes []*ScorecardStructure) error
+	walkStructures = func(nodes []*ScorecardStructure) error {
+		for _, node := range nodes {
+			if node.Syllabus != nil && !paths[strings.Join(node.Syllabus, "\x00")] {
+				return fmt.Errorf("syllabus %q doesn't exist", strings.Join(node.Syllabus, " / "))
+			}
+			if err := walkStructures(node.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walkStructures(doc.ScorecardStructures); err != nil {
+		return nil, err
+	}
+
+	gradeScaleTitles := make(map[string]bool)
+	for _, gradeScale := range doc.GradeScales {
+		if gradeScaleTitles[gradeScale.Title] {
+			return nil, fmt.Errorf("grade scale %q is used more than once", gradeScale.Title)
+		}
+		gradeScaleTitles[gradeScale.Title] = true
+
+		if err := gradeScale.Grades.Validate(); err != nil {
+			return nil, fmt.Errorf("grade scale %q: %w", gradeScale.Title, err)
+		}
+	}
+
+	return &doc, nil
+}
+
+// Instantiate creates the content of the document in an existing, empty program
+func Instantiate(ctx context.Context, tx *sqlx.Tx, programID int, doc *Document) error {
+	var structureIds []int
+	for _, title := range doc.SyllabusStructures {
+		var prevID *int
+		if n := len(structureIds); n > 0 {
+			prevID = &structureIds[n-1]
+		}
+
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO syllabus_structures (program_id, prev_id, title)
+			VALUES (?, ?, ?)
+			RETURNING id
+		`, programID, prevID, title).Scan(&id)
+		if err != nil {
+			return err
+		}
+		structureIds = append(structureIds, id)
+	}
+
+	if len(structureIds) > 0 {
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO syllabus_structures (program_id, prev_id, title)
+			VALUES (?, -1, 'Assignment')
+			RETURNING id
+		`, programID).Scan(&id)
+		if err != nil {
+			return err
+		}
+		structureIds = append(structureIds, id)
+	}
+
+	syllabusIds := make(map[string]int)
+	var insertSyllabuses func(nodes []*Syllabus, parentID *int, parents []string) error
+	insertSyllabuses = func(nodes []*Syllabus, parentID *int, parents []string) error {
+		for i, node := range nodes {
+			var id int
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO syllabuses (parent_id, structure_id, title, position)
+				VALUES (?, ?, ?, ?)
+				RETURNING id
+			`, parentID, structureIds[len(parents)], node.Title, i).Scan(&id)
+			if err != nil {
+				return err
+			}
+
+			path := append(parents[:len(parents):len(parents)], node.Title)
+			syllabusIds[strings.Join(path, "\x00")] = id
+			if err := insertSyllabuses(node.Children, &id, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := insertSyllabuses(doc.Syllabuses, nil, nil); err != nil {
+		return err
+	}
+
+	var gradeScaleID *int
+	for _, gradeScale := range doc.GradeScales {
+		var id int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO grade_scales (program_id, title, grades)
+			VALUES (?, ?, ?)
+			RETURNING id
+		`, programID, gradeScale.Title, gradeScale.Grades).Scan(&id)
+		if err != nil {
+			return err
+		}
+		if gradeScaleID == nil {
+			gradeScaleID = &id
+		}
+	}
+
+	// The structures belong to the default definition, which every program is created with
+	var definitionID int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO scorecard_definitions (program_id, title, grade_scale_id)
+		VALUES (?, ?, ?)
+		RETURNING id
+	`, programID, model.DefaultScorecardDefinitionTitle, gradeScaleID).Scan(&definitionID)
+	if err != nil {
+		return err
+	}
+
+	if len(doc.ScorecardStructures) == 0 {
+		return nil
+	}
+
+	var insertStructures func(nodes []*ScorecardStructure, parentID *int) error
+	insertStructures = func(nodes []*ScorecardStructure, parentID *int) error {
+		for i, node := range nodes {
+			var syllabusID *int
+			if node.Syllabus != nil {
+				if v, ok := syllabusIds[strings.Join(node.Syllabus, "\x00")]; ok {
+					syllabusID = &v
+				}
+			}
+
+			weight := 1.0
+			if node.Weight != nil {
+				weight = *node.Weight
+			}
+
+			var id int
+			err := tx.QueryRowContext(ctx, `
+				INSERT INTO scorecard_structures (program_id, definition_id, parent_id, title, syllabus_id, position, weight)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+				RETURNING id
+			`, programID, definitionID, parentID, node.Title, syllabusID, i, weight).Scan(&id)
+			if err != nil {
+				return err
+			}
+
+			if err := insertStructures(node.Children, &id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return insertStructures(doc.ScorecardStructures, nil)
+}
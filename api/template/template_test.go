@@ -0,0 +1,126 @@
+package template
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/brantem/scorecard/testutil/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const document = `{
+  "syllabusStructures": ["Syllabus"],
+  "syllabuses": [
+    { "title": "Syllabus 1", "children": [{ "title": "Assignment 1" }] }
+  ],
+  "scorecardStructures": [
+    { "title": "Total", "children": [{ "title": "Assignment 1", "syllabus": ["Syllabus 1", "Assignment 1"], "weight": 2 }] }
+  ],
+  "gradeScales": [
+    { "title": "Letter", "grades": [{ "grade": "A", "minScore": 90 }, { "grade": "B", "minScore": 80 }] },
+    { "title": "Pass/Fail", "grades": [{ "grade": "Pass", "minScore": 60 }] }
+  ]
+}`
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		name string
+		data string
+		ok   bool
+	}{
+		{"valid", document, true},
+		{"invalid json", `{`, false},
+		{"missing fields", `{"syllabusStructures":[]}`, false},
+		{"unknown field", `{"syllabusStructures":[],"syllabuses":[],"scorecardStructures":[],"weights":{}}`, false},
+		{"reserved structure title", `{"syllabusStructures":["Assignment"],"syllabuses":[],"scorecardStructures":[]}`, false},
+		{"without structures", `{"syllabusStructures":[],"syllabuses":[{"title":"Assignment 1"}],"scorecardStructures":[]}`, false},
+		{
+			"too deep",
+			`{"syllabusStructures":["Syllabus"],"syllabuses":[{"title":"Syllabus 1","children":[{"title":"Assignment 1","children":[{"title":"Assignment 2"}]}]}],"scorecardStructures":[]}`,
+			false,
+		},
+		{
+			"duplicate title",
+			`{"syllabusStructures":["Syllabus"],"syllabuses":[{"title":"Syllabus 1","children":[{"title":"Assignment 1"}]},{"title":"Syllabus 2","children":[{"title":"Assignment 1"}]}],"scorecardStructures":[]}`,
+			false,
+		},
+		{
+			"invalid weight",
+			`{"syllabusStructures":[],"syllabuses":[],"scorecardStructures":[{"title":"Total","weight":0}]}`,
+			false,
+		},
+		{
+			"duplicate grade scale",
+			`{"syllabusStructures":[],"syllabuses":[],"scorecardStructures":[],"gradeScales":[{"title":"Letter","grades":[]},{"title":"Letter","grades":[]}]}`,
+			false,
+		},
+		{
+			"duplicate grade",
+			`{"syllabusStructures":[],"syllabuses":[],"scorecardStructures":[],"gradeScales":[{"title":"Letter","grades":[{"grade":"A","minScore":90},{"grade":"A","minScore":80}]}]}`,
+			false,
+		},
+		{
+			"unknown syllabus",
+			`{"syllabusStructures":["Syllabus"],"syllabuses":[{"title":"Syllabus 1"}],"scorecardStructures":[{"title":"Total","syllabus":["Syllabus 2"]}]}`,
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.data))
+			if tt.ok {
+				assert.Nil(err)
+				assert.NotNil(doc)
+			} else {
+				assert.NotNil(err)
+				assert.Nil(doc)
+			}
+		})
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Run("success", func(t *testing.T) {
+		doc, err := Parse([]byte(document))
+		assert.Nil(err)
+
+		db, mock := db.New()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2, nil, "Syllabus").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery("INSERT INTO syllabus_structures").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+		mock.ExpectQuery("INSERT INTO syllabuses").WithArgs(nil, 1, "Syllabus 1", 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery("INSERT INTO syllabuses").WithArgs(3, 2, "Assignment 1", 0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+		mock.ExpectQuery("INSERT INTO grade_scales").WithArgs(2, "Letter", `[{"grade":"A","minScore":90},{"grade":"B","minScore":80}]`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectQuery("INSERT INTO grade_scales").WithArgs(2, "Pass/Fail", `[{"grade":"Pass","minScore":60}]`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectQuery("INSERT INTO scorecard_definitions").WithArgs(2, "Scorecard", 8).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").WithArgs(2, 7, nil, "Total", nil, 0, 1.0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+		mock.ExpectQuery("INSERT INTO scorecard_structures").WithArgs(2, 7, 5, "Assignment 1", 4, 0, 2.0).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+		mock.ExpectCommit()
+
+		tx := db.MustBegin()
+		assert.Nil(Instantiate(context.Background(), tx, 2, doc))
+		assert.Nil(tx.Commit())
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		db, mock := db.New()
+
+		// The default definition is created even without any scorecard structures
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO scorecard_definitions").WithArgs(2, "Scorecard", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectCommit()
+
+		tx := db.MustBegin()
+		assert.Nil(Instantiate(context.Background(), tx, 2, &Document{}))
+		assert.Nil(tx.Commit())
+		assert.Nil(mock.ExpectationsWereMet())
+	})
+}
@@ -27,6 +27,10 @@ func (m *Middleware) Syllabus(c *fiber.Ctx) error {
 	return c.Next()
 }
 
+func (m *Middleware) ScorecardDefinition(c *fiber.Ctx) error {
+	return c.Next()
+}
+
 func (m *Middleware) ScorecardStructure(c *fiber.Ctx) error {
 	return c.Next()
 }
@@ -34,3 +38,19 @@ func (m *Middleware) ScorecardStructure(c *fiber.Ctx) error {
 func (m *Middleware) Scorecard(c *fiber.Ctx) error {
 	return c.Next()
 }
+
+func (m *Middleware) ScorecardSchedule(c *fiber.Ctx) error {
+	return c.Next()
+}
+
+func (m *Middleware) GradeScale(c *fiber.Ctx) error {
+	return c.Next()
+}
+
+func (m *Middleware) Template(c *fiber.Ctx) error {
+	return c.Next()
+}
+
+func (m *Middleware) NotFinalized(c *fiber.Ctx) error {
+	return c.Next()
+}
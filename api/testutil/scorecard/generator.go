@@ -7,9 +7,13 @@ import (
 )
 
 type Generator struct {
-	EnqueueProgramID   []int
-	EnqueueUserID      []int
-	EnqueueScorecardID []int
+	EnqueueProgramID    []int
+	EnqueueDefinitionID []int
+	EnqueueUserID       []int
+	EnqueueScorecardID  []int
+	EnqueuePriority     []scorecard.Priority
+
+	CancelProgramID []int
 }
 
 func NewGenerator() *Generator {
@@ -26,8 +30,19 @@ func (g *Generator) IsInQueue(scorecardID int) bool {
 	return false
 }
 
-func (g *Generator) Enqueue(ctx context.Context, programID, userID, scorecardID int) {
+func (g *Generator) InQueue(scorecardIds []int) map[int]bool {
+	return map[int]bool{}
+}
+
+func (g *Generator) Enqueue(ctx context.Context, programID, definitionID, userID, scorecardID int, priority scorecard.Priority) scorecard.EnqueueStatus {
 	g.EnqueueProgramID = append(g.EnqueueProgramID, programID)
+	g.EnqueueDefinitionID = append(g.EnqueueDefinitionID, definitionID)
 	g.EnqueueUserID = append(g.EnqueueUserID, userID)
 	g.EnqueueScorecardID = append(g.EnqueueScorecardID, scorecardID)
+	g.EnqueuePriority = append(g.EnqueuePriority, priority)
+	return scorecard.EnqueueStatusQueued
+}
+
+func (g *Generator) Cancel(programID int) {
+	g.CancelProgramID = append(g.CancelProgramID, programID)
 }
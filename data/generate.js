@@ -25,6 +25,7 @@ for (let i = 1; i <= PROGRAMS; i++) {
   ];
   const title = titleCase(titles[Math.floor(Math.random() * titles.length)]);
   fs.appendFileSync(path, `INSERT INTO programs (id, title) VALUES (${i}, '${title}');\n`);
+  fs.appendFileSync(path, `INSERT INTO scorecard_definitions (program_id, title) VALUES (${i}, 'Scorecard');\n`);
 }
 
 fs.appendFileSync(path, '\n');